@@ -45,7 +45,7 @@ func init() {
 	
 	// Run migrations
 	log.Println("Running auto migrations...")
-	err = DB.AutoMigrate(&models.Log{}, &models.Client{}, &models.MappingRule{})
+	err = DB.AutoMigrate(&models.Log{}, &models.Client{}, &models.MappingRule{}, &models.MappingSetVersion{}, &models.Fixture{}, &models.Schema{}, &models.SubMapping{})
 	if err != nil {
 		log.Printf("Warning: Failed to run auto migrations: %v", err)
 	}
@@ -54,6 +54,9 @@ func init() {
 	if err := migrations.AddRequiredFieldsToMappingRules(DB); err != nil {
 		log.Printf("Warning: Failed to run custom migrations: %v", err)
 	}
+	if err := migrations.PublishExistingMappingSets(DB); err != nil {
+		log.Printf("Warning: Failed to publish existing mapping sets: %v", err)
+	}
 	
 	log.Println("Database initialization complete")
 }
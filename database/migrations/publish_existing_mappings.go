@@ -0,0 +1,69 @@
+package migrations
+
+import (
+	"data_mapping/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// appliedMigration records a one-time migration that has run.
+type appliedMigration struct {
+	Name      string `gorm:"primaryKey;size:100"`
+	AppliedAt time.Time
+}
+
+func (appliedMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// PublishExistingMappingSets publishes version 1 for clients that had mapping
+// rules before published versions existed, so their live transformations
+// keep working once transforms only use published rules. It runs once; later
+// clients go through the draft and publish workflow. The run is recorded
+// first, in the same transaction, so that instances starting together do not
+// both publish.
+func PublishExistingMappingSets(db *gorm.DB) error {
+	if err := db.AutoMigrate(&appliedMigration{}); err != nil {
+		return err
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&appliedMigration{
+			Name:      "publish_existing_mapping_sets",
+			AppliedAt: time.Now().UTC(),
+		})
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		var clients []models.Client
+		if err := tx.Where("active_version = 0 OR active_version IS NULL").Find(&clients).Error; err != nil {
+			return err
+		}
+
+		for _, client := range clients {
+			var rules []models.MappingRule
+			if err := tx.Where("client_id = ?", client.ID).Order("id").Find(&rules).Error; err != nil {
+				return err
+			}
+			if len(rules) == 0 {
+				continue
+			}
+
+			version := models.MappingSetVersion{
+				ClientID: client.ID,
+				Version:  1,
+				Rules:    rules,
+				Comment:  "Initial version published from existing mapping rules",
+			}
+			if err := tx.Create(&version).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&client).Update("active_version", version.Version).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -1,6 +1,6 @@
 import React, { useState, useEffect, useRef } from 'react';
-import { Plus, Trash2, Settings, AlertCircle, Info, Code, Save, X, Upload, Download, FileText } from 'lucide-react';
-import { clientsAPI, mappingAPI } from '../services/api';
+import { Plus, Trash2, Settings, AlertCircle, Info, Code, Save, X, Upload, Download, FileText, Send } from 'lucide-react';
+import { clientsAPI, mappingAPI, versionAPI } from '../services/api';
 import toast from 'react-hot-toast';
 import { Button } from '@/components/ui/button';
 import { Card, CardContent, CardDescription, CardHeader, CardTitle } from '@/components/ui/card';
@@ -32,6 +32,7 @@ const Clients = () => {
   const [showBulkMappingForm, setShowBulkMappingForm] = useState(false);
   const [bulkMappingText, setBulkMappingText] = useState('');
   const [savingBulkMapping, setSavingBulkMapping] = useState(false);
+  const [publishing, setPublishing] = useState(false);
   const fileInputRef = useRef(null);
 
   useEffect(() => {
@@ -96,6 +97,22 @@ const Clients = () => {
     loadMappings(client.id);
   };
 
+  // Rules are saved to the client's draft; transforms only use them once
+  // the draft is published as a version
+  const handlePublish = async () => {
+    if (!selectedClient) return;
+
+    setPublishing(true);
+    try {
+      const result = await versionAPI.publish(selectedClient.id, '');
+      toast.success(`Published version ${result.data.version}`);
+    } catch (error) {
+      console.error('Publish error:', error);
+    } finally {
+      setPublishing(false);
+    }
+  };
+
   const handleCreateMapping = async (e) => {
     e.preventDefault();
     if (!selectedClient) return;
@@ -416,7 +433,7 @@ const Clients = () => {
               Mapping Rules for {selectedClient?.name}
             </DialogTitle>
             <DialogDescription>
-              Manage mapping rules to transform data for this client.
+              Manage mapping rules to transform data for this client. Changes are saved as a draft and go live when published.
             </DialogDescription>
           </DialogHeader>
           
@@ -457,6 +474,14 @@ const Clients = () => {
                   <Plus className="h-4 w-4 mr-1" />
                   Add Rule
                 </Button>
+                <Button
+                  onClick={handlePublish}
+                  size="sm"
+                  disabled={publishing || mappings.length === 0}
+                >
+                  <Send className="h-4 w-4 mr-1" />
+                  {publishing ? 'Publishing...' : 'Publish'}
+                </Button>
               </div>
             </div>
 
@@ -84,6 +84,14 @@ export const mappingAPI = {
   }
 };
 
+// Versions API
+export const versionAPI = {
+  publish: async (clientId, comment) => {
+    const response = await api.post(`/clients/${clientId}/versions`, { comment });
+    return response.data;
+  }
+};
+
 // Transform API
 export const transformAPI = {
   transform: async (clientId, inputData) => {
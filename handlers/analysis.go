@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// AnalyzeCoverage reports which leaves of a sample input the client's rules
+// never read and which rules find no source value in it. The published rules
+// are analyzed by default and the draft with target=draft.
+func AnalyzeCoverage(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		rules, target, version, ok := loadTargetRules(c, db, client)
+		if !ok {
+			return
+		}
+		if rules, ok = withSubMappings(c, db, rules); !ok {
+			return
+		}
+
+		var request models.TransformationRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid JSON input",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		input, unmatched, ok := requestInput(c, request)
+		if !ok {
+			return
+		}
+		if rules, ok = routeRules(c, client.Routing, input, rules); !ok {
+			return
+		}
+
+		response := gin.H{
+			"success":  true,
+			"target":   target,
+			"coverage": utils.AnalyzeCoverage(input, rules),
+		}
+		if len(unmatched) > 0 {
+			response["unmatched_records"] = unmatched
+		}
+		if version > 0 {
+			response["version"] = version
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
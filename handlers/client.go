@@ -3,8 +3,10 @@ package handlers
 import (
 	"data_mapping/models"
 	"data_mapping/utils"
+	"fmt"
 	"net/http"
 	"strconv"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -62,15 +64,22 @@ func ListClients(db *gorm.DB) gin.HandlerFunc {
 
 func DeleteClient(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		id, err := strconv.Atoi(c.Param("id"))
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid ID format"})
+		id, ok := parseID(c, "id")
+		if !ok {
 			return
 		}
 		if result := db.Where("client_id = ?", id).Delete(&models.MappingRule{}); result.Error != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 			return
 		}
+		if result := db.Where("client_id = ?", id).Delete(&models.MappingSetVersion{}); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result := db.Where("client_id = ?", id).Delete(&models.Fixture{}); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
 		if result := db.Delete(&models.Client{}, id); result.Error != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 			return
@@ -78,3 +87,200 @@ func DeleteClient(db *gorm.DB) gin.HandlerFunc {
 		c.Status(http.StatusNoContent)
 	}
 }
+
+func UpdateClient(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseID(c, "id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, id); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		var req models.UpdateClientRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if err := utils.ValidateStruct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if req.Name != nil {
+			client.Name = *req.Name
+		}
+		if req.OutputFormat != nil {
+			client.OutputFormat = *req.OutputFormat
+		}
+		if req.OutputOptions != nil {
+			if utf8.RuneCountInString(req.OutputOptions.CSVDelimiter) > 1 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": "csv_delimiter must be a single character",
+				})
+				return
+			}
+			if req.OutputOptions.FixedWidth != nil {
+				if err := utils.ValidateFixedWidthLayout(*req.OutputOptions.FixedWidth); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"error":   "Validation failed",
+						"details": err.Error(),
+					})
+					return
+				}
+			}
+			client.OutputOptions = *req.OutputOptions
+		}
+		if req.PassThrough != nil {
+			if err := utils.ValidatePassThrough(*req.PassThrough); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": err.Error(),
+				})
+				return
+			}
+			client.PassThrough = *req.PassThrough
+		}
+		for _, ref := range []*string{req.InputSchema, req.OutputSchema} {
+			if ref == nil || *ref == "" {
+				continue
+			}
+			var count int64
+			db.Model(&models.Schema{}).Where("name = ?", *ref).Count(&count)
+			if count == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": "schema " + *ref + " not found",
+				})
+				return
+			}
+		}
+		if req.InputSchema != nil {
+			client.InputSchema = *req.InputSchema
+		}
+		if req.OutputSchema != nil {
+			client.OutputSchema = *req.OutputSchema
+		}
+		if req.SplitOn != nil {
+			if models.IsJSONPathString(*req.SplitOn) {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": "split_on must be a dotted path to an input array",
+				})
+				return
+			}
+			client.SplitOn = *req.SplitOn
+		}
+		if req.Routing != nil {
+			sets, err := clientMappingSets(db, client)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if err := utils.ValidateRouting(*req.Routing, sets); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": err.Error(),
+				})
+				return
+			}
+			client.Routing = *req.Routing
+		}
+		if req.Pipeline != nil {
+			if err := utils.ValidatePipeline(*req.Pipeline); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": err.Error(),
+				})
+				return
+			}
+			client.Pipeline = *req.Pipeline
+		}
+		if req.TransformMode != nil {
+			client.TransformMode = *req.TransformMode
+		}
+		if req.Template != nil {
+			if err := utils.ValidateTemplate(req.Template.Data); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Validation failed",
+					"details": err.Error(),
+				})
+				return
+			}
+			client.Template = *req.Template
+		}
+		if req.RequirePassingFixtures != nil {
+			client.RequirePassingFixtures = *req.RequirePassingFixtures
+		}
+		if client.OutputFormat == "fixedwidth" && client.OutputOptions.FixedWidth == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": "output_format 'fixedwidth' requires output_options.fixed_width",
+			})
+			return
+		}
+		if client.TransformMode == models.TransformModeTemplate && client.Template.Data == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": "transform_mode 'template' requires a template",
+			})
+			return
+		}
+
+		if result := db.Save(&client); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to update client",
+				"details": result.Error.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    client,
+		})
+	}
+}
+
+// clientMappingSets lists the mapping sets a client's routing may name: those
+// of its draft rules and of its active version.
+func clientMappingSets(db *gorm.DB, client models.Client) ([]string, error) {
+	rules, err := loadDraftRules(db, client.ID)
+	if err != nil {
+		return nil, err
+	}
+	if client.ActiveVersion > 0 {
+		active, err := findVersion(db, client.ID, client.ActiveVersion)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, active.Rules...)
+	}
+	return utils.MappingSets(rules), nil
+}
+
+// parseID reads the numeric ID in the named path parameter. It answers 400
+// and reports false when the parameter is not a positive integer, so that the
+// raw text never reaches a query.
+func parseID(c *gin.Context, name string) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param(name), 10, 0)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid ID format",
+			"details": fmt.Sprintf("%s must be a positive integer, got %q", name, c.Param(name)),
+		})
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+func TestParseID(t *testing.T) {
+	for raw, want := range map[string]uint{
+		"1":        1,
+		"42":       42,
+		"0":        0,
+		"-1":       0,
+		"1 OR 1=1": 0,
+		"1;DROP":   0,
+		"":         0,
+		"0x10":     0,
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "client_id", Value: raw}}
+
+		id, ok := parseID(c, "client_id")
+		if ok != (want != 0) || id != want {
+			t.Errorf("parseID(%q) = %d, %v; want %d", raw, id, ok, want)
+		}
+		if !ok && w.Code != http.StatusBadRequest {
+			t.Errorf("parseID(%q) answered %d, want 400", raw, w.Code)
+		}
+	}
+}
+
+// Handlers reject a malformed ID before touching the database, which is nil
+// here.
+func TestHandlersRejectInjectedIDs(t *testing.T) {
+	router := gin.New()
+	router.PATCH("/clients/:id", UpdateClient(nil))
+	router.DELETE("/clients/:id", DeleteClient(nil))
+	router.POST("/clients/:client_id/transform", UnifiedTransformHandler(nil))
+	router.GET("/clients/:client_id/mappings", GetMappings(nil))
+	router.DELETE("/mappings/:mapping_id", DeleteMappings(nil))
+	router.POST("/clients/:client_id/versions", PublishMappings(nil))
+	router.GET("/clients/:client_id/versions", ListVersions(nil))
+	router.GET("/clients/:client_id/versions/:version", GetVersion(nil))
+	router.GET("/clients/:client_id/versions-diff", DiffVersions(nil))
+	router.POST("/clients/:client_id/versions/:version/rollback", RollbackVersion(nil))
+	router.GET("/clients/:client_id/draft", GetDraft(nil))
+	router.POST("/clients/:client_id/draft/preview", PreviewDraft(nil))
+	router.POST("/clients/:client_id/draft/discard", DiscardDraft(nil))
+	router.POST("/clients/:client_id/fixtures", CreateFixture(nil))
+	router.GET("/clients/:client_id/fixtures", ListFixtures(nil))
+	router.POST("/clients/:client_id/fixtures/run", RunFixtures(nil))
+	router.DELETE("/fixtures/:fixture_id", DeleteFixture(nil))
+	router.PUT("/clients/:client_id/mappings", ReplaceMappings(nil))
+	router.POST("/clients/:client_id/mappings/import", ImportMappings(nil))
+	router.GET("/clients/:client_id/mappings/export", ExportMappings(nil))
+	router.GET("/clients/:client_id/mappings/conflicts", GetMappingConflicts(nil))
+	router.POST("/clients/:client_id/analysis/coverage", AnalyzeCoverage(nil))
+	router.GET("/clients/:client_id/schema/output", GetOutputSchema(nil))
+	router.GET("/clients/:client_id/schema/input", GetInputSchema(nil))
+	router.POST("/clients/:client_id/mappings/suggest", SuggestMappings(nil))
+	router.GET("/mappings/:mapping_id", GetMapping(nil))
+	router.PUT("/mappings/:mapping_id", UpdateMapping(nil))
+	router.PATCH("/mappings/:mapping_id", PatchMapping(nil))
+
+	for _, req := range []struct{ method, path string }{
+		{http.MethodPatch, "/clients/1%20OR%201=1"},
+		{http.MethodDelete, "/clients/abc"},
+		{http.MethodPost, "/clients/1%20OR%201=1/transform"},
+		{http.MethodGet, "/clients/-1/mappings"},
+		{http.MethodDelete, "/mappings/1%20OR%201=1"},
+		{http.MethodPost, "/clients/1%20OR%201=1/versions"},
+		{http.MethodGet, "/clients/abc/versions"},
+		{http.MethodGet, "/clients/1;DROP/versions/1"},
+		{http.MethodGet, "/clients/0/versions-diff?from=1"},
+		{http.MethodPost, "/clients/1%20OR%201=1/versions/1/rollback"},
+		{http.MethodGet, "/clients/1%20OR%201=1/draft"},
+		{http.MethodPost, "/clients/abc/draft/preview"},
+		{http.MethodPost, "/clients/1;DROP/draft/discard"},
+		{http.MethodPost, "/clients/1%20OR%201=1/fixtures"},
+		{http.MethodGet, "/clients/1%20OR%201=1/fixtures"},
+		{http.MethodPost, "/clients/abc/fixtures/run"},
+		{http.MethodDelete, "/fixtures/1%20OR%201=1"},
+		{http.MethodPut, "/clients/1%20OR%201=1/mappings"},
+		{http.MethodPost, "/clients/1%20OR%201=1/mappings/import"},
+		{http.MethodGet, "/clients/1%20OR%201=1/mappings/export?version=1"},
+		{http.MethodGet, "/clients/1%20OR%201=1/mappings/conflicts"},
+		{http.MethodPost, "/clients/1%20OR%201=1/analysis/coverage"},
+		{http.MethodGet, "/clients/1%20OR%201=1/schema/output"},
+		{http.MethodGet, "/clients/abc/schema/input"},
+		{http.MethodPost, "/clients/1%20OR%201=1/mappings/suggest"},
+		{http.MethodGet, "/mappings/1%20OR%201=1"},
+		{http.MethodPut, "/mappings/abc"},
+		{http.MethodPatch, "/mappings/1;DROP"},
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(req.method, req.path, nil))
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s = %d, want 400", req.method, req.path, w.Code)
+		}
+	}
+}
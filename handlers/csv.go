@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// transformCSV transforms a text/csv request body row by row. Parsing is
+// configured through the delimiter, quoting, encoding and infer_types query
+// parameters; the list of outputs is written with the selected encoder. Each
+// row is checked against the client's input and output schemas and routed to
+// its mapping set like a JSON request. The sets used are listed in the
+// X-Mapping-Set response header in order of first use.
+func transformCSV(c *gin.Context, db *gorm.DB, client models.Client, enc utils.Encoder, rules []models.MappingRule, transformOpts utils.TransformOptions) {
+	opts, err := csvOptionsFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid CSV options",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	records, err := utils.ParseCSVRecords(c.Request.Body, opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid CSV input",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	inputSchema, ok := loadClientSchema(c, db, client.InputSchema)
+	if !ok {
+		return
+	}
+	var outputSchema *utils.SchemaSet
+	if transformOpts.Pipeline.Validates() {
+		if outputSchema, ok = loadClientSchema(c, db, client.OutputSchema); !ok {
+			return
+		}
+	}
+
+	override := c.GetHeader("X-Mapping-Set")
+	var sets []string
+	usedSet := make(map[string]bool)
+
+	outputs := make([]map[string]interface{}, 0, len(records))
+	var rowWarnings []gin.H
+	var rowViolations []gin.H
+	var rowSchemaErrors []gin.H
+	for i, record := range records {
+		inputErrs, ok := validateWithSchema(c, inputSchema, client.InputSchema, record)
+		if !ok {
+			return
+		}
+		if len(inputErrs) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":  fmt.Sprintf("Input row %d failed schema validation", i+1),
+				"schema": client.InputSchema,
+				"row":    i + 1,
+				"errors": inputErrs,
+			})
+			return
+		}
+
+		rowRules := rules
+		if client.TransformMode != models.TransformModeTemplate {
+			set, selected, err := utils.RouteMappingSet(record, rules, client.Routing, override)
+			if err != nil {
+				status := http.StatusUnprocessableEntity
+				if override != "" {
+					status = http.StatusBadRequest
+				}
+				c.JSON(status, gin.H{
+					"error":   fmt.Sprintf("Could not select a mapping set for row %d", i+1),
+					"details": err.Error(),
+				})
+				return
+			}
+			if set != "" && !usedSet[set] {
+				usedSet[set] = true
+				sets = append(sets, set)
+			}
+			rowRules = selected
+		}
+
+		output, err := utils.TransformWithOptions(record, rowRules, transformOpts)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   fmt.Sprintf("Transformation failed for row %d", i+1),
+				"details": err.Error(),
+			})
+			return
+		}
+		if !transformOpts.Pipeline.Validates() {
+			outputs = append(outputs, output)
+			continue
+		}
+		violations, warnings := validateOutput(output, rowRules)
+		if warnings != nil {
+			rowViolations = append(rowViolations, gin.H{"row": i + 1, "violations": violations})
+		}
+		outputErrs, ok := validateWithSchema(c, outputSchema, client.OutputSchema, output)
+		if !ok {
+			return
+		}
+		if len(outputErrs) > 0 {
+			if warnings == nil {
+				warnings = gin.H{}
+			}
+			warnings["outputSchemaErrors"] = outputErrs
+			rowSchemaErrors = append(rowSchemaErrors, gin.H{"row": i + 1, "errors": outputErrs})
+		}
+		if warnings != nil {
+			warnings["row"] = i + 1
+			rowWarnings = append(rowWarnings, warnings)
+		}
+		outputs = append(outputs, output)
+	}
+
+	if respondStrictViolations(c, transformOpts, rowViolations, len(rowViolations)) {
+		return
+	}
+	if len(rowSchemaErrors) > 0 && strictValidation(c, transformOpts) {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{
+			"error":  "Output failed schema validation",
+			"schema": client.OutputSchema,
+			"rows":   rowSchemaErrors,
+		})
+		return
+	}
+
+	if len(sets) > 0 {
+		c.Header("X-Mapping-Set", strings.Join(sets, ","))
+	}
+
+	var warnings gin.H
+	if len(rowWarnings) > 0 {
+		warnings = gin.H{"rows": rowWarnings}
+	}
+	if !transformOpts.Pipeline.Validates() {
+		warnings = gin.H{"validationSkipped": true}
+	}
+	writeTransformResult(c, enc, outputs, warnings, nil)
+}
+
+// csvOptionsFromRequest builds parsing options from query parameters, falling
+// back to the charset of the Content-Type header for the encoding.
+func csvOptionsFromRequest(c *gin.Context) (utils.CSVOptions, error) {
+	opts := utils.CSVOptions{
+		Quoting:    c.DefaultQuery("quoting", "strict"),
+		Encoding:   c.Query("encoding"),
+		InferTypes: c.Query("infer_types") == "true",
+	}
+
+	if opts.Encoding == "" {
+		if _, params, err := mime.ParseMediaType(c.GetHeader("Content-Type")); err == nil {
+			opts.Encoding = params["charset"]
+		}
+	}
+
+	switch delimiter := c.DefaultQuery("delimiter", ","); delimiter {
+	case "tab", `\t`:
+		opts.Delimiter = '\t'
+	default:
+		r, size := utf8.DecodeRuneInString(delimiter)
+		if size != len(delimiter) || r == '"' || r == '\r' || r == '\n' {
+			return opts, fmt.Errorf("delimiter must be a single character, got %q", delimiter)
+		}
+		opts.Delimiter = r
+	}
+
+	return opts, nil
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Per-row warnings reach the caller whatever the output format: in the JSON
+// envelope, or in the X-Transform-Warnings header for NDJSON.
+func TestTransformCSVReturnsRowWarnings(t *testing.T) {
+	rules := []models.MappingRule{{
+		SourcePath:      models.JSONStringList{"amount"},
+		DestinationPath: models.JSONStringList{"amount"},
+		TransformType:   "copy",
+		Constraints:     &models.Constraints{Type: "number"},
+	}}
+	body := "amount\n12\nlots\n"
+
+	transform := func(enc utils.Encoder) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/clients/1/transform?infer_types=true", strings.NewReader(body))
+		c.Request.Header.Set("Content-Type", "text/csv")
+		transformCSV(c, nil, models.Client{ID: 1}, enc, rules, utils.TransformOptions{})
+		if w.Code != http.StatusOK {
+			t.Fatalf("%T: status %d: %s", enc, w.Code, w.Body.String())
+		}
+		return w
+	}
+
+	var envelope struct {
+		Warnings struct {
+			Rows []map[string]interface{} `json:"rows"`
+		} `json:"warnings"`
+	}
+	if err := json.Unmarshal(transform(utils.JSONEncoder{}).Body.Bytes(), &envelope); err != nil {
+		t.Fatal(err)
+	}
+	if len(envelope.Warnings.Rows) != 1 || envelope.Warnings.Rows[0]["row"] != 2.0 {
+		t.Fatalf("JSON warnings = %+v, want one for row 2", envelope.Warnings.Rows)
+	}
+
+	w := transform(utils.NDJSONEncoder{})
+	if lines := strings.Count(w.Body.String(), "\n"); lines != 2 {
+		t.Errorf("NDJSON body has %d lines, want 2", lines)
+	}
+	var header struct {
+		Rows []map[string]interface{} `json:"rows"`
+	}
+	if err := json.Unmarshal([]byte(w.Header().Get("X-Transform-Warnings")), &header); err != nil {
+		t.Fatalf("X-Transform-Warnings: %v", err)
+	}
+	if !reflect.DeepEqual(header.Rows, envelope.Warnings.Rows) {
+		t.Errorf("NDJSON warnings = %+v, want %+v", header.Rows, envelope.Warnings.Rows)
+	}
+}
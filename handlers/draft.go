@@ -0,0 +1,197 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// The client's mapping_rules rows form its draft workspace. Rules can be
+// created and deleted there freely; transforms only see them once the draft
+// is published as a version.
+
+// GetDraft returns the draft rules together with their differences from the
+// active published version. The draft is compared with its sub-mappings
+// expanded, as publishing would snapshot it, so edits to a sub-mapping count
+// as changes.
+func GetDraft(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		rules, err := loadDraftRules(db, client.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		var published []models.MappingRule
+		if client.ActiveVersion > 0 {
+			active, err := findVersion(db, client.ID, client.ActiveVersion)
+			if err != nil {
+				respondVersionError(c, err)
+				return
+			}
+			published = active.Rules
+		}
+		expanded, ok := withSubMappings(c, db, rules)
+		if !ok {
+			return
+		}
+		changes := utils.DiffRuleSets(published, expanded)
+
+		c.JSON(http.StatusOK, gin.H{
+			"base_version": client.ActiveVersion,
+			"has_changes":  changes.HasChanges(),
+			"changes":      changes,
+			"rules":        rules,
+		})
+	}
+}
+
+// PreviewDraft transforms a sample input with the draft rules without
+// affecting live transformations.
+func PreviewDraft(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		var request models.TransformationRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid JSON input",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		input, unmatched, ok := requestInput(c, request)
+		if !ok {
+			return
+		}
+
+		rules, err := loadDraftRules(db, client.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(rules) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Draft has no mapping rules"})
+			return
+		}
+		rules, ok = withSubMappings(c, db, rules)
+		if !ok {
+			return
+		}
+		if rules, ok = routeRules(c, client.Routing, input, rules); !ok {
+			return
+		}
+
+		// The preview exercises the draft rules even for template clients
+		opts := transformOptions(client)
+		opts.Template = nil
+		opts.Explain = c.Query("explain") == "true"
+		documents, traces, err := utils.TransformDocuments(input, rules, opts)
+		if err != nil {
+			respondTransformError(c, err)
+			return
+		}
+
+		violations, warnings := validateDocuments(documents, rules, opts)
+		if respondStrictViolations(c, opts, violations, len(violations)) {
+			return
+		}
+
+		response := gin.H{
+			"success": true,
+			"draft":   true,
+			"data":    documentsResult(documents, opts),
+		}
+		if len(unmatched) > 0 {
+			if warnings == nil {
+				warnings = gin.H{}
+			}
+			warnings["unmatchedRecords"] = unmatched
+		}
+		if warnings != nil {
+			response["warnings"] = warnings
+		}
+		if explain := explainResult(traces, opts); explain != nil {
+			response["explain"] = explain
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// DiscardDraft resets the draft rules to those of the active published
+// version, keeping the original rule IDs. Sub-mappings go back to being
+// referenced by name, without the rules the version snapshotted for them.
+func DiscardDraft(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+		if client.ActiveVersion == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Client has no published version to reset the draft to"})
+			return
+		}
+
+		active, err := findVersion(db, client.ID, client.ActiveVersion)
+		if err != nil {
+			respondVersionError(c, err)
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("client_id = ?", client.ID).Delete(&models.MappingRule{}).Error; err != nil {
+				return err
+			}
+			if len(active.Rules) == 0 {
+				return nil
+			}
+			rules := utils.CollapseSubMappings(active.Rules)
+			return tx.Create(&rules).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to discard draft",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success":      true,
+			"base_version": client.ActiveVersion,
+		})
+	}
+}
+
+func loadDraftRules(db *gorm.DB, clientID uint) ([]models.MappingRule, error) {
+	var rules []models.MappingRule
+	err := db.Where("client_id = ?", clientID).Order("id").Find(&rules).Error
+	return rules, err
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"testing"
+)
+
+var addressRules = []models.MappingRule{
+	{SourcePath: models.JSONStringList{"city"}, DestinationPath: models.JSONStringList{"town"}, TransformType: "copy"},
+}
+
+func draftWithSubMapping() []models.MappingRule {
+	return []models.MappingRule{
+		{ID: 1, ClientID: 3, SourcePath: models.JSONStringList{"id"}, DestinationPath: models.JSONStringList{"ref"}, TransformType: "copy"},
+		{ID: 2, ClientID: 3, SourcePath: models.JSONStringList{"home"}, DestinationPath: models.JSONStringList{"address"}, TransformType: "subMapping", TransformLogic: "address"},
+	}
+}
+
+// expandWith expands rules against the given sub-mappings without a
+// database.
+func expandWith(t *testing.T, rules []models.MappingRule, subMappings map[string][]models.MappingRule) []models.MappingRule {
+	t.Helper()
+	expanded, err := expandRules(nil, rules, subMappings, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return expanded
+}
+
+// A freshly published draft compares equal to its version once both sides
+// are expanded, and an edit to the sub-mapping shows up as a change.
+func TestDraftComparesExpandedRules(t *testing.T) {
+	published := expandWith(t, draftWithSubMapping(), map[string][]models.MappingRule{"address": addressRules})
+
+	draft := expandWith(t, draftWithSubMapping(), map[string][]models.MappingRule{"address": addressRules})
+	if diff := utils.DiffRuleSets(published, draft); diff.HasChanges() {
+		t.Errorf("unchanged draft differs from its version: %+v", diff)
+	}
+
+	edited := append(append([]models.MappingRule(nil), addressRules...), models.MappingRule{
+		SourcePath: models.JSONStringList{"zip"}, DestinationPath: models.JSONStringList{"postcode"}, TransformType: "copy",
+	})
+	draft = expandWith(t, draftWithSubMapping(), map[string][]models.MappingRule{"address": edited})
+	if diff := utils.DiffRuleSets(published, draft); len(diff.Changed) != 1 {
+		t.Errorf("edited sub-mapping diff = %+v, want the subMapping rule changed", diff)
+	}
+}
+
+// Discarding the draft restores the rules as they were stored before publish.
+func TestDiscardedDraftReferencesSubMappingsByName(t *testing.T) {
+	published := expandWith(t, draftWithSubMapping(), map[string][]models.MappingRule{"address": addressRules})
+	restored := utils.CollapseSubMappings(published)
+
+	if diff := utils.DiffRuleSets(draftWithSubMapping(), restored); diff.HasChanges() {
+		t.Errorf("restored draft differs from the original: %+v", diff)
+	}
+	for i, rule := range restored {
+		if err := utils.ValidateMappingRule(rule); err != nil {
+			t.Errorf("restored[%d]: %v", i, err)
+		}
+	}
+}
@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// fixtureResult is the outcome of running one fixture.
+type fixtureResult struct {
+	Fixture     string                  `json:"fixture"`
+	Passed      bool                    `json:"passed"`
+	Error       string                  `json:"error,omitempty"`
+	Differences []utils.FieldDifference `json:"differences"`
+}
+
+// fixtureReport summarizes a fixture run against one rule set.
+type fixtureReport struct {
+	Target  string          `json:"target"`
+	Version int             `json:"version,omitempty"`
+	Passed  int             `json:"passed"`
+	Failed  int             `json:"failed"`
+	Results []fixtureResult `json:"results"`
+}
+
+func CreateFixture(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		var req models.CreateFixtureRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err := utils.ValidateStruct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		var existing int64
+		db.Model(&models.Fixture{}).Where("client_id = ? AND name = ?", client.ID, req.Name).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "A fixture with this name already exists"})
+			return
+		}
+
+		fixture := models.Fixture{
+			ClientID: client.ID,
+			Name:     req.Name,
+			Input:    models.JSONDocument{Data: req.Input},
+			Expected: models.JSONDocument{Data: req.Expected},
+		}
+		if result := db.Create(&fixture); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create fixture",
+				"details": result.Error.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"data":    fixture,
+		})
+	}
+}
+
+func ListFixtures(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var fixtures []models.Fixture
+		result := db.Where("client_id = ?", clientID).Order("name").Find(&fixtures)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, fixtures)
+	}
+}
+
+func DeleteFixture(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseID(c, "fixture_id")
+		if !ok {
+			return
+		}
+		result := db.Delete(&models.Fixture{}, id)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Fixture not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// RunFixtures runs every fixture of a client against the published rules, or
+// against the draft when target=draft. A specific published version can be
+// chosen with the version query parameter. The run itself succeeds when
+// fixtures fail; the report says which.
+func RunFixtures(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		rules, target, version, ok := loadTargetRules(c, db, client)
+		if !ok {
+			return
+		}
+
+		report, err := runClientFixtures(db, client, rules)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		report.Target = target
+		report.Version = version
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// runClientFixtures transforms every stored fixture input with rules and
+// compares the result with the expected output.
+func runClientFixtures(db *gorm.DB, client models.Client, rules []models.MappingRule) (fixtureReport, error) {
+	rules, err := expandSubMappings(db, rules)
+	if err != nil {
+		return fixtureReport{}, err
+	}
+
+	var fixtures []models.Fixture
+	if err := db.Where("client_id = ?", client.ID).Order("name").Find(&fixtures).Error; err != nil {
+		return fixtureReport{}, err
+	}
+
+	// Fixtures gate publishing rules, so they exercise the rules even for
+	// template clients
+	opts := transformOptions(client)
+	opts.Template = nil
+
+	report := fixtureReport{Results: []fixtureResult{}}
+	for _, fixture := range fixtures {
+		result := fixtureResult{Fixture: fixture.Name, Differences: []utils.FieldDifference{}}
+		output, err := transformFixture(fixture, rules, client.Routing, opts)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.Differences = utils.CompareDocuments(fixture.Expected.Data, output)
+		}
+		result.Passed = result.Error == "" && len(result.Differences) == 0
+
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+func transformFixture(fixture models.Fixture, rules []models.MappingRule, routing models.Routing, opts utils.TransformOptions) (interface{}, error) {
+	input, ok := fixture.Input.Data.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("fixture input is not a JSON object")
+	}
+	_, rules, err := utils.RouteMappingSet(input, rules, routing, "")
+	if err != nil {
+		return nil, err
+	}
+	documents, _, err := utils.TransformDocuments(input, rules, opts)
+	if err != nil {
+		return nil, err
+	}
+	return documentsResult(documents, opts), nil
+}
+
+// fixtureFailureSummary names the failing fixtures of a report.
+func fixtureFailureSummary(report fixtureReport) string {
+	var names []string
+	for _, result := range report.Results {
+		if !result.Passed {
+			names = append(names, result.Fixture)
+		}
+	}
+	return strconv.Itoa(report.Failed) + " fixture(s) failed: " + strings.Join(names, ", ")
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bytes"
+	"data_mapping/models"
+	"data_mapping/utils"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ImportMappings loads a mapping set into the client's draft in a single
+// transaction. The body is a JSON array of rules whose paths may be dotted
+// strings or arrays, an export document, or CSV or YAML when sent with the
+// matching content type. Every rule is validated before anything is written
+// and all problems are reported together. With mode=upsert (the default) an
+// imported rule updates the draft rule with the same set and destination path;
+// mode=replace discards the rest of the draft.
+func ImportMappings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+
+		mode := c.DefaultQuery("mode", "upsert")
+		if mode != "upsert" && mode != "replace" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "mode must be 'upsert' or 'replace'"})
+			return
+		}
+
+		rules, ruleErrors, err := decodeImportBody(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		failed := make(map[int]bool, len(ruleErrors))
+		for _, ruleErr := range ruleErrors {
+			failed[ruleErr.Index] = true
+		}
+		for i := range rules {
+			rules[i].ID = 0
+			rules[i].ClientID = clientID
+			if failed[i] {
+				continue
+			}
+			if failure := validateRule(i, rules[i]); failure != nil {
+				ruleErrors = append(ruleErrors, utils.RuleError{
+					Index:   i,
+					Error:   fmt.Sprint(failure["error"]),
+					Details: fmt.Sprint(failure["details"]),
+				})
+			}
+		}
+		if len(ruleErrors) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":  fmt.Sprintf("Validation failed for %d rule(s)", len(ruleErrors)),
+				"errors": ruleErrors,
+			})
+			return
+		}
+		if len(rules) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No mapping rules to import"})
+			return
+		}
+
+		var created, updated, deleted int
+		var saved []models.MappingRule
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var client models.Client
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&client, clientID).Error; err != nil {
+				return &requestError{http.StatusNotFound, gin.H{"error": "Client not found"}}
+			}
+
+			current, err := loadDraftRules(tx, client.ID)
+			if err != nil {
+				return err
+			}
+
+			if mode == "replace" {
+				result := tx.Where("client_id = ?", client.ID).Delete(&models.MappingRule{})
+				if result.Error != nil {
+					return result.Error
+				}
+				deleted = int(result.RowsAffected)
+				created = len(rules)
+				if err := tx.Create(&rules).Error; err != nil {
+					return err
+				}
+			} else {
+				byDestination := make(map[string]models.MappingRule, len(current))
+				for _, rule := range current {
+					key := utils.RuleKey(rule)
+					if _, ok := byDestination[key]; !ok {
+						byDestination[key] = rule
+					}
+				}
+				for i := range rules {
+					key := utils.RuleKey(rules[i])
+					if existing, ok := byDestination[key]; ok {
+						delete(byDestination, key)
+						rules[i].ID = existing.ID
+						rules[i].CreatedAt = existing.CreatedAt
+						if err := tx.Save(&rules[i]).Error; err != nil {
+							return err
+						}
+						updated++
+						continue
+					}
+					if err := tx.Create(&rules[i]).Error; err != nil {
+						return err
+					}
+					created++
+				}
+			}
+
+			var touched map[uint]bool
+			if mode == "upsert" {
+				touched = make(map[uint]bool, len(rules))
+				for _, rule := range rules {
+					touched[rule.ID] = true
+				}
+			}
+			if err := verifyDraftRules(tx, client.ID, touched); err != nil {
+				return err
+			}
+
+			saved, err = loadDraftRules(tx, client.ID)
+			return err
+		})
+		if err != nil {
+			respondRequestError(c, err)
+			return
+		}
+
+		c.Header("ETag", ruleSetETag(saved))
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"mode":    mode,
+			"created": created,
+			"updated": updated,
+			"deleted": deleted,
+			"data":    saved,
+		})
+	}
+}
+
+// decodeImportBody decodes the import body as CSV, YAML or JSON depending on
+// the request's content type.
+func decodeImportBody(c *gin.Context) ([]models.MappingRule, []utils.RuleError, error) {
+	if c.ContentType() == "text/csv" {
+		return utils.ParseMappingRulesCSV(c.Request.Body)
+	}
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	switch c.ContentType() {
+	case "application/yaml", "application/x-yaml", "text/yaml":
+		if data, err = utils.YAMLToJSON(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	return utils.DecodeMappingRules(data)
+}
+
+// ExportMappings writes the client's draft rules, or a published version when
+// the version query parameter is given, as a portable json, csv or yaml file
+// that ImportMappings accepts unchanged.
+func ExportMappings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		format := c.DefaultQuery("format", "json")
+		contentType, ok := exportContentTypes[format]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of json, csv or yaml"})
+			return
+		}
+
+		var export utils.MappingExport
+		if raw := c.Query("version"); raw != "" {
+			version, err := strconv.Atoi(raw)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+				return
+			}
+			snapshot, err := findVersion(db, client.ID, version)
+			if err != nil {
+				respondVersionError(c, err)
+				return
+			}
+			export = utils.NewMappingExport(client.Name, version, false, snapshot.Rules)
+		} else {
+			rules, err := loadDraftRules(db, client.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			export = utils.NewMappingExport(client.Name, client.ActiveVersion, true, rules)
+		}
+
+		var buf bytes.Buffer
+		if err := utils.WriteMappingExport(&buf, export, format); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to export mapping rules",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		filename := fmt.Sprintf("%s-mappings.%s", exportFilenamePattern.ReplaceAllString(client.Name, "_"), format)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+		c.Data(http.StatusOK, contentType, buf.Bytes())
+	}
+}
+
+var exportContentTypes = map[string]string{
+	"json": "application/json; charset=utf-8",
+	"csv":  "text/csv; charset=utf-8",
+	"yaml": "application/yaml; charset=utf-8",
+}
+
+var exportFilenamePattern = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
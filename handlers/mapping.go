@@ -1,24 +1,29 @@
 package handlers
 
 import (
+	"bytes"
+	"crypto/sha1"
 	"data_mapping/models"
 	"data_mapping/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/antonmedv/expr"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func CreateMappings(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientID, err := strconv.Atoi(c.Param("client_id"))
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Invalid client ID",
-			})
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
 			return
 		}
 
@@ -33,48 +38,32 @@ func CreateMappings(db *gorm.DB) gin.HandlerFunc {
 
 		// Set ClientID and validate each rule
 		for i := range rules {
-			rules[i].ClientID = uint(clientID)
-
-			// Special validation for expression type
-			if rules[i].TransformType == "expression" {
-				if rules[i].TransformLogic == "" {
-					c.JSON(http.StatusBadRequest, gin.H{
-						"error":   "Validation failed for rule " + strconv.Itoa(i),
-						"details": "TransformLogic is required when TransformType is 'expression'",
-					})
-					return
-				}
-
-				// Try to validate expression syntax
-				if _, err := expr.Compile(rules[i].TransformLogic); err != nil {
-					c.JSON(http.StatusBadRequest, gin.H{
-						"error":   "Invalid expression syntax in rule " + strconv.Itoa(i),
-						"details": err.Error(),
-					})
-					return
-				}
-			}
-
-			// Validate the rule after setting required fields using custom validation
-			if err := utils.ValidateMappingRule(rules[i]); err != nil {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error":   "Validation failed for rule " + strconv.Itoa(i),
-					"details": err.Error(),
-				})
+			rules[i].ClientID = clientID
+			if failure := validateRule(i, rules[i]); failure != nil {
+				c.JSON(http.StatusBadRequest, failure)
 				return
 			}
+		}
 
-			// Validate required fields have appropriate defaults
-			if rules[i].Required && rules[i].DefaultValue == "" {
-				log.Printf("Warning: Required field mapping without default value: %v -> %v",
-					rules[i].SourcePath, rules[i].DestinationPath)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&rules).Error; err != nil {
+				return err
+			}
+			touched := make(map[uint]bool, len(rules))
+			for _, rule := range rules {
+				touched[rule.ID] = true
 			}
+			return verifyDraftRules(tx, clientID, touched)
+		})
+		var reqErr *requestError
+		if errors.As(err, &reqErr) {
+			c.JSON(reqErr.status, reqErr.body)
+			return
 		}
-
-		if result := db.Create(&rules); result.Error != nil {
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{
 				"error":   "Failed to create mapping rules",
-				"details": result.Error.Error(),
+				"details": err.Error(),
 			})
 			return
 		}
@@ -88,20 +77,27 @@ func CreateMappings(db *gorm.DB) gin.HandlerFunc {
 
 func GetMappings(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientID := c.Param("client_id")
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
 		var rules []models.MappingRule
-		result := db.Where("client_id = ?", clientID).Find(&rules)
+		result := db.Where("client_id = ?", clientID).Order("id").Find(&rules)
 		if result.Error != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
 			return
 		}
+		c.Header("ETag", ruleSetETag(rules))
 		c.JSON(http.StatusOK, rules)
 	}
 }
 
 func DeleteMappings(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		mappingID := c.Param("mapping_id")
+		mappingID, ok := parseID(c, "mapping_id")
+		if !ok {
+			return
+		}
 		result := db.Delete(&models.MappingRule{}, mappingID)
 		if result.Error != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
@@ -114,3 +110,410 @@ func DeleteMappings(db *gorm.DB) gin.HandlerFunc {
 		c.Status(http.StatusNoContent)
 	}
 }
+
+// validateRule runs the save-time checks for a mapping rule and returns the
+// error response body for the first problem found, or nil when it is valid.
+func validateRule(i int, rule models.MappingRule) gin.H {
+	// Special validation for expression type
+	if rule.TransformType == "expression" {
+		if rule.TransformLogic == "" {
+			return gin.H{
+				"error":   "Validation failed for rule " + strconv.Itoa(i),
+				"details": "TransformLogic is required when TransformType is 'expression'",
+			}
+		}
+
+		// Try to validate expression syntax
+		if _, err := expr.Compile(rule.TransformLogic); err != nil {
+			return gin.H{
+				"error":   "Invalid expression syntax in rule " + strconv.Itoa(i),
+				"details": err.Error(),
+			}
+		}
+	}
+
+	// Validate the rule after setting required fields using custom validation
+	if err := utils.ValidateMappingRule(rule); err != nil {
+		return gin.H{
+			"error":   "Validation failed for rule " + strconv.Itoa(i),
+			"details": err.Error(),
+		}
+	}
+
+	// Validate required fields have appropriate defaults
+	if rule.Required && rule.DefaultValue == "" {
+		log.Printf("Warning: Required field mapping without default value: %v -> %v",
+			rule.SourcePath, rule.DestinationPath)
+	}
+	return nil
+}
+
+func GetMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseID(c, "mapping_id")
+		if !ok {
+			return
+		}
+		var rule models.MappingRule
+		if result := db.First(&rule, id); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Mapping rule not found"})
+			return
+		}
+		c.Header("ETag", ruleETag(rule))
+		c.JSON(http.StatusOK, rule)
+	}
+}
+
+// UpdateMapping replaces a single mapping rule, keeping its ID. The request
+// must carry an If-Match header or the rule's current updated_at value.
+func UpdateMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseID(c, "mapping_id")
+		if !ok {
+			return
+		}
+		var rule models.MappingRule
+		if err := c.ShouldBindJSON(&rule); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		updated, err := saveRuleRevision(db, c, id, rule.UpdatedAt, func(current models.MappingRule) (models.MappingRule, *requestError) {
+			return rule, nil
+		})
+		if err != nil {
+			respondRequestError(c, err)
+			return
+		}
+
+		c.Header("ETag", ruleETag(updated))
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    updated,
+		})
+	}
+}
+
+// PatchMapping updates only the fields present in the request body. The
+// request must carry an If-Match header or the rule's current updated_at value.
+func PatchMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id, ok := parseID(c, "mapping_id")
+		if !ok {
+			return
+		}
+		var patch map[string]json.RawMessage
+		if err := c.ShouldBindJSON(&patch); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		for _, field := range []string{"id", "client_id", "created_at"} {
+			if _, ok := patch[field]; ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Field '" + field + "' cannot be changed"})
+				return
+			}
+		}
+
+		var updatedAt time.Time
+		if raw, ok := patch["updated_at"]; ok {
+			if err := json.Unmarshal(raw, &updatedAt); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Invalid updated_at value",
+					"details": err.Error(),
+				})
+				return
+			}
+			delete(patch, "updated_at")
+		}
+
+		updated, err := saveRuleRevision(db, c, id, updatedAt, func(current models.MappingRule) (models.MappingRule, *requestError) {
+			merged := make(map[string]json.RawMessage)
+			data, _ := json.Marshal(current)
+			json.Unmarshal(data, &merged)
+			for field, value := range patch {
+				merged[field] = value
+			}
+
+			data, _ = json.Marshal(merged)
+			dec := json.NewDecoder(bytes.NewReader(data))
+			dec.DisallowUnknownFields()
+			var rule models.MappingRule
+			if err := dec.Decode(&rule); err != nil {
+				return rule, &requestError{http.StatusBadRequest, gin.H{
+					"error":   "Invalid request body",
+					"details": err.Error(),
+				}}
+			}
+			return rule, nil
+		})
+		if err != nil {
+			respondRequestError(c, err)
+			return
+		}
+
+		c.Header("ETag", ruleETag(updated))
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    updated,
+		})
+	}
+}
+
+// ReplaceMappings replaces a client's whole draft rule set in one transaction.
+// The If-Match header must carry the ETag returned by GetMappings, or "*".
+// Rules that keep the ID of an existing rule of the set retain that ID.
+func ReplaceMappings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+
+		ifMatch := c.GetHeader("If-Match")
+		if ifMatch == "" {
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "An If-Match header is required to replace mapping rules"})
+			return
+		}
+
+		var rules []models.MappingRule
+		if err := c.ShouldBindJSON(&rules); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		for i := range rules {
+			rules[i].ClientID = clientID
+			if failure := validateRule(i, rules[i]); failure != nil {
+				c.JSON(http.StatusBadRequest, failure)
+				return
+			}
+		}
+
+		var saved []models.MappingRule
+		err := db.Transaction(func(tx *gorm.DB) error {
+			var client models.Client
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&client, clientID).Error; err != nil {
+				return &requestError{http.StatusNotFound, gin.H{"error": "Client not found"}}
+			}
+
+			var current []models.MappingRule
+			if err := tx.Where("client_id = ?", client.ID).Order("id").Find(&current).Error; err != nil {
+				return err
+			}
+			if !etagMatches(ifMatch, ruleSetETag(current)) {
+				return &requestError{http.StatusPreconditionFailed, gin.H{
+					"error": "Mapping rules were modified by another request",
+					"etag":  ruleSetETag(current),
+				}}
+			}
+
+			replacementRules(current, rules, time.Now())
+			if err := tx.Where("client_id = ?", client.ID).Delete(&models.MappingRule{}).Error; err != nil {
+				return err
+			}
+			if len(rules) > 0 {
+				if err := tx.Create(&rules).Error; err != nil {
+					return err
+				}
+			}
+			if err := verifyDraftRules(tx, client.ID, nil); err != nil {
+				return err
+			}
+			return tx.Where("client_id = ?", client.ID).Order("id").Find(&saved).Error
+		})
+		if err != nil {
+			respondRequestError(c, err)
+			return
+		}
+
+		c.Header("ETag", ruleSetETag(saved))
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    saved,
+		})
+	}
+}
+
+// replacementRules prepares rules to replace current. Rules whose ID is not in
+// current get a new one, and every rule is stamped with now so that the set's
+// ETag changes even when a rule is sent back unchanged.
+func replacementRules(current, rules []models.MappingRule, now time.Time) {
+	existing := make(map[uint]bool, len(current))
+	for _, rule := range current {
+		existing[rule.ID] = true
+	}
+	for i := range rules {
+		if !existing[rules[i].ID] {
+			rules[i].ID = 0
+		}
+		rules[i].CreatedAt = time.Time{}
+		rules[i].UpdatedAt = now
+	}
+}
+
+// GetMappingConflicts analyzes the client's draft rules for overlapping
+// destination paths, including overrides that are explicitly allowed.
+func GetMappingConflicts(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var rules []models.MappingRule
+		if result := db.Where("client_id = ?", clientID).Order("id").Find(&rules); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+
+		conflicts := utils.AnalyzeDestinations(rules)
+		c.JSON(http.StatusOK, gin.H{
+			"conflicts": conflicts,
+			"blocking":  len(utils.BlockingConflicts(conflicts, nil)),
+		})
+	}
+}
+
+// verifyDraftRules analyzes the client's draft after a write and fails with
+// 409 Conflict when a rule in touched, or any rule when touched is nil, takes
+// part in a destination conflict that is not marked allow_override. It fails
+// with 422 when a rule references a missing or cyclic sub-mapping.
+func verifyDraftRules(tx *gorm.DB, clientID uint, touched map[uint]bool) error {
+	var rules []models.MappingRule
+	if err := tx.Where("client_id = ?", clientID).Order("id").Find(&rules).Error; err != nil {
+		return err
+	}
+
+	var filter func(utils.ConflictRule) bool
+	if touched != nil {
+		filter = func(rule utils.ConflictRule) bool { return touched[rule.ID] }
+	}
+	if conflicts := utils.BlockingConflicts(utils.AnalyzeDestinations(rules), filter); len(conflicts) > 0 {
+		return &requestError{http.StatusConflict, gin.H{
+			"error":     "Conflicting destination paths",
+			"details":   "Set allow_override on the later rule to keep an intentional override",
+			"conflicts": conflicts,
+		}}
+	}
+	_, err := expandSubMappings(tx, rules)
+	return err
+}
+
+// requestError carries the HTTP response for a failure detected inside a
+// database transaction.
+type requestError struct {
+	status int
+	body   gin.H
+}
+
+func (e *requestError) Error() string {
+	return fmt.Sprint(e.body["error"])
+}
+
+func respondRequestError(c *gin.Context, err error) {
+	var reqErr *requestError
+	if errors.As(err, &reqErr) {
+		c.JSON(reqErr.status, reqErr.body)
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error":   "Failed to save mapping rules",
+		"details": err.Error(),
+	})
+}
+
+// saveRuleRevision locks the rule with the given ID, checks the optimistic
+// concurrency precondition, builds the new revision with build, validates and
+// stores it, and returns the stored rule.
+func saveRuleRevision(db *gorm.DB, c *gin.Context, id uint, updatedAt time.Time, build func(current models.MappingRule) (models.MappingRule, *requestError)) (models.MappingRule, error) {
+	var saved models.MappingRule
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current models.MappingRule
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&current, id).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return &requestError{http.StatusNotFound, gin.H{"error": "Mapping rule not found"}}
+			}
+			return err
+		}
+		if reqErr := checkRulePrecondition(c, current, updatedAt); reqErr != nil {
+			return reqErr
+		}
+
+		rule, reqErr := build(current)
+		if reqErr != nil {
+			return reqErr
+		}
+		rule.ID = current.ID
+		rule.ClientID = current.ClientID
+		rule.CreatedAt = current.CreatedAt
+		if failure := validateRule(0, rule); failure != nil {
+			return &requestError{http.StatusBadRequest, failure}
+		}
+
+		if err := tx.Save(&rule).Error; err != nil {
+			return err
+		}
+		if err := verifyDraftRules(tx, rule.ClientID, map[uint]bool{rule.ID: true}); err != nil {
+			return err
+		}
+		return tx.First(&saved, rule.ID).Error
+	})
+	return saved, err
+}
+
+// checkRulePrecondition compares the If-Match header, or else the updated_at
+// value sent by the client, with the stored revision of the rule.
+func checkRulePrecondition(c *gin.Context, current models.MappingRule, updatedAt time.Time) *requestError {
+	conflict := &requestError{http.StatusPreconditionFailed, gin.H{
+		"error":   "Mapping rule was modified by another request",
+		"current": current,
+	}}
+
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		if !etagMatches(ifMatch, ruleETag(current)) {
+			return conflict
+		}
+		return nil
+	}
+	if updatedAt.IsZero() {
+		return &requestError{http.StatusPreconditionRequired, gin.H{
+			"error": "An If-Match header or the rule's updated_at value is required",
+		}}
+	}
+	if !updatedAt.Truncate(time.Microsecond).Equal(current.UpdatedAt.Truncate(time.Microsecond)) {
+		return conflict
+	}
+	return nil
+}
+
+// ruleETag identifies a stored revision of a mapping rule.
+func ruleETag(rule models.MappingRule) string {
+	return fmt.Sprintf(`"%d-%d"`, rule.ID, rule.UpdatedAt.UnixMicro())
+}
+
+// ruleSetETag identifies the stored revision of a client's whole rule set.
+func ruleSetETag(rules []models.MappingRule) string {
+	h := sha1.New()
+	for _, rule := range rules {
+		fmt.Fprintf(h, "%d-%d;", rule.ID, rule.UpdatedAt.UnixMicro())
+	}
+	return fmt.Sprintf(`"set-%x"`, h.Sum(nil)[:8])
+}
+
+// etagMatches reports whether an If-Match header lists etag or "*".
+func etagMatches(ifMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestReplacementRulesChangeSetETag(t *testing.T) {
+	stored := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	current := []models.MappingRule{
+		{ID: 1, ClientID: 7, SourcePath: models.JSONStringList{"a"}, DestinationPath: models.JSONStringList{"x"}, CreatedAt: stored, UpdatedAt: stored},
+		{ID: 2, ClientID: 7, SourcePath: models.JSONStringList{"b"}, DestinationPath: models.JSONStringList{"y"}, CreatedAt: stored, UpdatedAt: stored},
+	}
+
+	// The client sends the set back unchanged, plus a rule with a foreign ID
+	rules := append([]models.MappingRule(nil), current...)
+	rules = append(rules, models.MappingRule{ID: 99, ClientID: 7, SourcePath: models.JSONStringList{"c"}, DestinationPath: models.JSONStringList{"z"}})
+	now := stored.Add(time.Minute)
+	replacementRules(current, rules, now)
+
+	if rules[0].ID != 1 || rules[1].ID != 2 || rules[2].ID != 0 {
+		t.Errorf("IDs = %d, %d, %d; want 1, 2, 0", rules[0].ID, rules[1].ID, rules[2].ID)
+	}
+	for i, rule := range rules {
+		if !rule.UpdatedAt.Equal(now) || !rule.CreatedAt.IsZero() {
+			t.Errorf("rules[%d] created %v, updated %v; want zero and %v", i, rule.CreatedAt, rule.UpdatedAt, now)
+		}
+	}
+	if ruleSetETag(rules[:2]) == ruleSetETag(current) {
+		t.Error("replacing the set with the same rules kept its ETag")
+	}
+}
+
+// Two writers that read the same revision cannot both save: the second one
+// presents a stale ETag or updated_at and gets 412.
+func TestCheckRulePrecondition(t *testing.T) {
+	read := time.Date(2024, 3, 1, 12, 0, 0, 123456789, time.UTC)
+	before := models.MappingRule{ID: 5, UpdatedAt: read}
+	after := models.MappingRule{ID: 5, UpdatedAt: read.Add(time.Second)}
+
+	for _, tc := range []struct {
+		name      string
+		ifMatch   string
+		updatedAt time.Time
+		current   models.MappingRule
+		want      int
+	}{
+		{"matching etag", ruleETag(before), time.Time{}, before, 0},
+		{"weak etag in a list", `"1-1", W/` + ruleETag(before), time.Time{}, before, 0},
+		{"any", "*", time.Time{}, after, 0},
+		{"stale etag", ruleETag(before), time.Time{}, after, http.StatusPreconditionFailed},
+		{"etag wins over updated_at", ruleETag(before), after.UpdatedAt, after, http.StatusPreconditionFailed},
+		{"matching updated_at", "", read.Truncate(time.Microsecond), before, 0},
+		{"stale updated_at", "", read, after, http.StatusPreconditionFailed},
+		{"no precondition", "", time.Time{}, before, http.StatusPreconditionRequired},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodPut, "/mappings/5", nil)
+		if tc.ifMatch != "" {
+			c.Request.Header.Set("If-Match", tc.ifMatch)
+		}
+
+		status := 0
+		if reqErr := checkRulePrecondition(c, tc.current, tc.updatedAt); reqErr != nil {
+			status = reqErr.status
+		}
+		if status != tc.want {
+			t.Errorf("%s: status %d, want %d", tc.name, status, tc.want)
+		}
+	}
+}
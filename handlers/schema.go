@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func CreateSchema(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CreateSchemaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err := utils.ValidateStruct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		var existing int64
+		db.Model(&models.Schema{}).Where("name = ?", req.Name).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "A schema with this name already exists"})
+			return
+		}
+		if !checkStoredSchema(c, db, req.Name, req.Document) {
+			return
+		}
+
+		schema := models.Schema{Name: req.Name, Document: models.JSONDocument{Data: req.Document}}
+		if result := db.Create(&schema); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create schema",
+				"details": result.Error.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"data":    schema,
+		})
+	}
+}
+
+func ListSchemas(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var schemas []models.Schema
+		if result := db.Order("name").Find(&schemas); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, schemas)
+	}
+}
+
+func GetSchema(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var schema models.Schema
+		if result := db.Where("name = ?", c.Param("name")).First(&schema); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schema not found"})
+			return
+		}
+		c.JSON(http.StatusOK, schema)
+	}
+}
+
+// UpdateSchema replaces a schema's document. The new document must still
+// resolve all of its $refs, and so must the schemas that refer to it.
+func UpdateSchema(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var schema models.Schema
+		if result := db.Where("name = ?", c.Param("name")).First(&schema); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schema not found"})
+			return
+		}
+
+		var req models.UpdateSchemaRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		if !checkStoredSchema(c, db, schema.Name, req.Document) {
+			return
+		}
+		if !checkDependentSchemas(c, db, schema.Name, schema.Document.Data, req.Document) {
+			return
+		}
+
+		schema.Document = models.JSONDocument{Data: req.Document}
+		if result := db.Save(&schema); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to update schema",
+				"details": result.Error.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    schema,
+		})
+	}
+}
+
+// DeleteSchema removes a schema unless a client still validates with it.
+func DeleteSchema(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		var users int64
+		db.Model(&models.Client{}).Where("input_schema = ? OR output_schema = ?", name, name).Count(&users)
+		if users > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Schema is used by a client"})
+			return
+		}
+
+		result := db.Where("name = ?", name).Delete(&models.Schema{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Schema not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// checkStoredSchema checks document as the schema called name alongside the
+// stored schemas it refers to. It writes the error response itself and
+// reports whether the caller may continue.
+func checkStoredSchema(c *gin.Context, db *gorm.DB, name string, document interface{}) bool {
+	set, err := loadSchemaSetWith(db, name, document, utils.SchemaRefs(document)...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	if err := set.CheckSchema(document); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid schema",
+			"details": err.Error(),
+		})
+		return false
+	}
+	return true
+}
+
+// checkDependentSchemas checks that the stored schemas whose $refs name the
+// schema called name, by name or $id, still resolve with document in its
+// place. It writes the error response itself and reports whether the caller
+// may continue.
+func checkDependentSchemas(c *gin.Context, db *gorm.DB, name string, previous, document interface{}) bool {
+	targets := map[string]bool{name: true}
+	if id := utils.SchemaID(previous); id != "" {
+		targets[id] = true
+	}
+
+	var candidates []models.Schema
+	if err := db.Where("name <> ? AND document::text LIKE ?", name, `%"$ref"%`).Find(&candidates).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return false
+	}
+	for _, dependent := range candidates {
+		refs := utils.SchemaRefs(dependent.Document.Data)
+		uses := false
+		for _, ref := range refs {
+			uses = uses || targets[ref]
+		}
+		if !uses {
+			continue
+		}
+		set, err := loadSchemaSetWith(db, name, document, append(refs, dependent.Name)...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return false
+		}
+		if err := set.CheckSchema(dependent.Document.Data); err != nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":   "Change breaks a schema that refers to this one",
+				"details": dependent.Name + ": " + err.Error(),
+			})
+			return false
+		}
+	}
+	return true
+}
+
+// loadSchemaSet loads the stored schemas called names and those their $refs
+// reach, by name or $id, so that refs between them resolve.
+func loadSchemaSet(db *gorm.DB, names ...string) (*utils.SchemaSet, error) {
+	set := utils.NewSchemaSet()
+	err := addReachableSchemas(db, set, map[string]bool{}, map[string]bool{}, names)
+	return set, err
+}
+
+// loadSchemaSetWith is loadSchemaSet with document standing in for the
+// stored schema called name, which may not exist yet. The stored document
+// is never loaded, not even through its old $id.
+func loadSchemaSetWith(db *gorm.DB, name string, document interface{}, names ...string) (*utils.SchemaSet, error) {
+	var stored []models.Schema
+	if err := db.Where("name = ?", name).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{name: true}
+	for _, schema := range stored {
+		if id := utils.SchemaID(schema.Document.Data); id != "" {
+			seen[id] = true
+		}
+	}
+	if id := utils.SchemaID(document); id != "" {
+		seen[id] = true
+	}
+
+	set := utils.NewSchemaSet()
+	set.Add(name, document)
+	err := addReachableSchemas(db, set, seen, map[string]bool{name: true}, append(names, utils.SchemaRefs(document)...))
+	return set, err
+}
+
+// addReachableSchemas adds the stored schemas named in pending, and those
+// their $refs reach, to set. Seen holds the names and $ids already looked
+// up and added the names of the schemas already in set.
+func addReachableSchemas(db *gorm.DB, set *utils.SchemaSet, seen, added map[string]bool, pending []string) error {
+	for len(pending) > 0 {
+		var lookup []string
+		for _, name := range pending {
+			if name != "" && !seen[name] {
+				seen[name] = true
+				lookup = append(lookup, name)
+			}
+		}
+		if len(lookup) == 0 {
+			return nil
+		}
+
+		var schemas []models.Schema
+		if err := db.Where("name IN ? OR TRIM(TRAILING '#' FROM document->>'$id') IN ?", lookup, lookup).Find(&schemas).Error; err != nil {
+			return err
+		}
+		pending = nil
+		for _, schema := range schemas {
+			if added[schema.Name] {
+				continue
+			}
+			added[schema.Name] = true
+			seen[schema.Name] = true
+			if id := utils.SchemaID(schema.Document.Data); id != "" {
+				seen[id] = true
+			}
+			set.Add(schema.Name, schema.Document.Data)
+			pending = append(pending, utils.SchemaRefs(schema.Document.Data)...)
+		}
+	}
+	return nil
+}
+
+// loadClientSchema loads the set for validating against the named schema.
+// It returns a nil set when name is empty. A failure is written as the
+// response and the caller must stop.
+func loadClientSchema(c *gin.Context, db *gorm.DB, name string) (*utils.SchemaSet, bool) {
+	if name == "" {
+		return nil, true
+	}
+	set, err := loadSchemaSet(db, name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return set, true
+}
+
+// validateWithSchema validates document against the named schema in set,
+// when there is one. An unusable schema is reported as an error response and
+// the caller must stop.
+func validateWithSchema(c *gin.Context, set *utils.SchemaSet, name string, document interface{}) ([]utils.SchemaError, bool) {
+	if set == nil {
+		return nil, true
+	}
+	errs, err := set.Validate(name, document)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Invalid schema configured for client",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return errs, true
+}
+
+// validateSchemaRef validates document against the named schema, if any. An
+// unusable schema is reported as an error response and the caller must stop.
+func validateSchemaRef(c *gin.Context, db *gorm.DB, name string, document interface{}) ([]utils.SchemaError, bool) {
+	set, ok := loadClientSchema(c, db, name)
+	if !ok {
+		return nil, false
+	}
+	return validateWithSchema(c, set, name, document)
+}
+
+// validateDocumentSchemas is validateSchemaRef for every transformed
+// document. When the input was split, instance locations start with the
+// document's index. Nothing is checked when the client pipeline skips
+// validation.
+func validateDocumentSchemas(c *gin.Context, db *gorm.DB, name string, documents []map[string]interface{}, opts utils.TransformOptions) ([]utils.SchemaError, bool) {
+	if !opts.Pipeline.Validates() {
+		return nil, true
+	}
+	if len(opts.SplitOn) == 0 {
+		return validateSchemaRef(c, db, name, documents[0])
+	}
+	set, ok := loadClientSchema(c, db, name)
+	if !ok || set == nil {
+		return nil, ok
+	}
+	var all []utils.SchemaError
+	for i, document := range documents {
+		errs, ok := validateWithSchema(c, set, name, document)
+		if !ok {
+			return nil, false
+		}
+		for _, e := range errs {
+			e.InstanceLocation = utils.JSONPointer(strconv.Itoa(i)) + e.InstanceLocation
+			all = append(all, e)
+		}
+	}
+	return all, true
+}
+
+// GetOutputSchema generates a JSON Schema of the document the client's rules
+// produce. The published rules are used by default and the draft with
+// target=draft; set picks a mapping set other than the routing default.
+func GetOutputSchema(db *gorm.DB) gin.HandlerFunc {
+	return generatedSchemaHandler(db, "output", utils.GenerateOutputSchema)
+}
+
+// GetInputSchema generates a JSON Schema of the input paths the client's
+// rules read.
+func GetInputSchema(db *gorm.DB) gin.HandlerFunc {
+	return generatedSchemaHandler(db, "input", utils.GenerateInputSchema)
+}
+
+func generatedSchemaHandler(db *gorm.DB, side string, generate func(string, []models.MappingRule) map[string]interface{}) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		rules, target, version, ok := loadTargetRules(c, db, client)
+		if !ok {
+			return
+		}
+		set := c.DefaultQuery("set", client.Routing.Default)
+		if rules = utils.RulesInSet(rules, set); len(rules) == 0 && set != "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Mapping set not found"})
+			return
+		}
+		if set != "" {
+			c.Header("X-Mapping-Set", set)
+		}
+
+		schema := generate(client.Name+" "+side, rules)
+		if target == "draft" {
+			schema["$comment"] = "Generated from the draft mapping rules"
+		} else {
+			schema["$comment"] = "Generated from mapping version " + strconv.Itoa(version)
+			c.Header("X-Mapping-Version", strconv.Itoa(version))
+		}
+		c.JSON(http.StatusOK, schema)
+	}
+}
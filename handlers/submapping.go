@@ -0,0 +1,267 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxSubMappingDepth bounds how deeply sub-mappings may reference one
+// another, which also stops reference cycles.
+const maxSubMappingDepth = 8
+
+// subMappingUsage matches JSONB rule lists that reference a sub-mapping
+// anywhere, including inside sub-rules. The jsonpath is bound as an argument
+// since its '?' and '@' would otherwise be read as placeholders.
+const subMappingUsage = "jsonb_path_exists(%s, ?::jsonpath, jsonb_build_object('name', ?::text))"
+
+const subMappingUsagePath = `$.** ? (@.transform_type == "subMapping" && @.transform_logic == $name)`
+
+func CreateSubMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.CreateSubMappingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err := utils.ValidateStruct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		var existing int64
+		db.Model(&models.SubMapping{}).Where("name = ?", req.Name).Count(&existing)
+		if existing > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "A sub-mapping with this name already exists"})
+			return
+		}
+
+		subMapping := models.SubMapping{Name: req.Name, Description: req.Description, Rules: req.Rules}
+		if !checkSubMapping(c, db, &subMapping) {
+			return
+		}
+		if result := db.Create(&subMapping); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to create sub-mapping",
+				"details": result.Error.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"data":    subMapping,
+		})
+	}
+}
+
+func ListSubMappings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var subMappings []models.SubMapping
+		if result := db.Order("name").Find(&subMappings); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, subMappings)
+	}
+}
+
+func GetSubMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var subMapping models.SubMapping
+		if result := db.Where("name = ?", c.Param("name")).First(&subMapping); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sub-mapping not found"})
+			return
+		}
+		c.JSON(http.StatusOK, subMapping)
+	}
+}
+
+// UpdateSubMapping replaces a sub-mapping's rules. Drafts that reference it
+// use the new rules from the next preview on. Published versions keep the
+// expanded rules they snapshotted, so clients pick up the change only once
+// they publish again.
+func UpdateSubMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var subMapping models.SubMapping
+		if result := db.Where("name = ?", c.Param("name")).First(&subMapping); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sub-mapping not found"})
+			return
+		}
+
+		var req models.UpdateSubMappingRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err := utils.ValidateStruct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		subMapping.Rules = req.Rules
+		if req.Description != nil {
+			subMapping.Description = *req.Description
+		}
+		if !checkSubMapping(c, db, &subMapping) {
+			return
+		}
+		if result := db.Save(&subMapping); result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to update sub-mapping",
+				"details": result.Error.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    subMapping,
+		})
+	}
+}
+
+// DeleteSubMapping removes a sub-mapping unless a draft rule, a published
+// version or another sub-mapping still references it.
+func DeleteSubMapping(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		name := c.Param("name")
+		var users int64
+		for _, query := range []*gorm.DB{
+			db.Model(&models.MappingRule{}).Where("(transform_type = 'subMapping' AND transform_logic = ?) OR "+fmt.Sprintf(subMappingUsage, "sub_rules"), name, subMappingUsagePath, name),
+			db.Model(&models.MappingSetVersion{}).Where(fmt.Sprintf(subMappingUsage, "rules"), subMappingUsagePath, name),
+			db.Model(&models.SubMapping{}).Where(fmt.Sprintf(subMappingUsage, "rules"), subMappingUsagePath, name),
+		} {
+			var count int64
+			if err := query.Count(&count).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			users += count
+		}
+		if users > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Sub-mapping is referenced by mapping rules"})
+			return
+		}
+
+		result := db.Where("name = ?", name).Delete(&models.SubMapping{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Sub-mapping not found"})
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// checkSubMapping validates a sub-mapping's rules, drops their client and
+// database fields, and makes sure the sub-mappings they reference exist
+// without forming a cycle. It writes the error response itself and reports
+// whether the caller may continue.
+func checkSubMapping(c *gin.Context, db *gorm.DB, subMapping *models.SubMapping) bool {
+	for i := range subMapping.Rules {
+		rule := &subMapping.Rules[i]
+		rule.ID, rule.ClientID = 0, 0
+		if failure := validateRule(i, *rule); failure != nil {
+			c.JSON(http.StatusBadRequest, failure)
+			return false
+		}
+	}
+
+	resolved := map[string][]models.MappingRule{subMapping.Name: subMapping.Rules}
+	_, err := expandRules(db, subMapping.Rules, resolved, 1)
+	return respondExpandError(c, err)
+}
+
+// withSubMappings expands the sub-mapping references in rules for a
+// transform. It writes the error response itself.
+func withSubMappings(c *gin.Context, db *gorm.DB, rules []models.MappingRule) ([]models.MappingRule, bool) {
+	expanded, err := expandSubMappings(db, rules)
+	return expanded, respondExpandError(c, err)
+}
+
+// respondExpandError writes the response for a failed sub-mapping expansion
+// and reports whether there was none.
+func respondExpandError(c *gin.Context, err error) bool {
+	var reqErr *requestError
+	switch {
+	case err == nil:
+		return true
+	case errors.As(err, &reqErr):
+		c.JSON(reqErr.status, reqErr.body)
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+	return false
+}
+
+// expandSubMappings returns a copy of rules in which every subMapping rule
+// carries the rules of the sub-mapping it names as its SubRules, resolved
+// recursively. Rules of published versions already carry the SubRules
+// snapshotted at publish time, which are kept. Unknown names and references
+// nested deeper than maxSubMappingDepth are reported as request errors.
+func expandSubMappings(db *gorm.DB, rules []models.MappingRule) ([]models.MappingRule, error) {
+	return expandRules(db, rules, make(map[string][]models.MappingRule), 0)
+}
+
+func expandRules(db *gorm.DB, rules []models.MappingRule, resolved map[string][]models.MappingRule, depth int) ([]models.MappingRule, error) {
+	expanded := make([]models.MappingRule, len(rules))
+	for i, rule := range rules {
+		switch {
+		case rule.TransformType == "subMapping" && len(rule.SubRules) == 0:
+			if depth >= maxSubMappingDepth {
+				return nil, &requestError{http.StatusUnprocessableEntity, gin.H{
+					"error":   "Sub-mappings are nested too deeply",
+					"details": fmt.Sprintf("sub-mapping %q is more than %d levels deep; check for a reference cycle", rule.TransformLogic, maxSubMappingDepth),
+				}}
+			}
+			subRules, ok := resolved[rule.TransformLogic]
+			if !ok {
+				var subMapping models.SubMapping
+				err := db.Where("name = ?", rule.TransformLogic).First(&subMapping).Error
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return nil, &requestError{http.StatusUnprocessableEntity, gin.H{
+						"error":   "Unknown sub-mapping",
+						"details": fmt.Sprintf("rule for %v references sub-mapping %q, which does not exist", rule.DestinationPath, rule.TransformLogic),
+					}}
+				}
+				if err != nil {
+					return nil, err
+				}
+				subRules = subMapping.Rules
+				resolved[rule.TransformLogic] = subRules
+			}
+			var err error
+			if rule.SubRules, err = expandRules(db, subRules, resolved, depth+1); err != nil {
+				return nil, err
+			}
+		case len(rule.SubRules) > 0:
+			var err error
+			if rule.SubRules, err = expandRules(db, rule.SubRules, resolved, depth); err != nil {
+				return nil, err
+			}
+		}
+		expanded[i] = rule
+	}
+	return expanded, nil
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultMinConfidence is the confidence a suggestion needs to be saved to
+// the draft when the request does not set min_confidence.
+const defaultMinConfidence = 0.5
+
+// SuggestMappings proposes mapping rules from sample input/output pairs. The
+// response lists the best suggestion per output path with its alternatives
+// and a rules array that the import endpoint accepts. With save_to_draft the
+// suggestions meeting min_confidence are added to the draft, skipping
+// destinations the draft already writes.
+func SuggestMappings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		var req models.SuggestMappingsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+		if err := utils.ValidateStruct(req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Validation failed",
+				"details": err.Error(),
+			})
+			return
+		}
+		minConfidence := defaultMinConfidence
+		if req.MinConfidence != nil {
+			minConfidence = *req.MinConfidence
+		}
+
+		report := utils.SuggestRules(req.Pairs)
+		rules := []models.MappingRule{}
+		for _, suggestion := range report.Suggestions {
+			if suggestion.Confidence >= minConfidence {
+				rule := suggestion.Rule
+				rule.ClientID = client.ID
+				rules = append(rules, rule)
+			}
+		}
+
+		response := gin.H{
+			"success":        true,
+			"suggestions":    report.Suggestions,
+			"unmatched":      report.Unmatched,
+			"min_confidence": minConfidence,
+			"rules":          rules,
+		}
+		if !req.SaveToDraft {
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		created, skipped, err := saveSuggestedRules(db, client.ID, rules)
+		if err != nil {
+			respondRequestError(c, err)
+			return
+		}
+		response["rules"] = created
+		response["skipped"] = skipped
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// saveSuggestedRules appends rules to the client's draft, leaving out those
+// whose destination the draft already writes, and returns the created rules
+// and the skipped destinations.
+func saveSuggestedRules(db *gorm.DB, clientID uint, rules []models.MappingRule) ([]models.MappingRule, []string, error) {
+	created := []models.MappingRule{}
+	skipped := []string{}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		draft, err := loadDraftRules(tx, clientID)
+		if err != nil {
+			return err
+		}
+		taken := make(map[string]bool, len(draft))
+		for _, rule := range draft {
+			taken[utils.RuleKey(rule)] = true
+		}
+
+		for i, rule := range rules {
+			destination := utils.RuleKey(rule)
+			if taken[destination] {
+				skipped = append(skipped, destination)
+				continue
+			}
+			if failure := validateRule(i, rule); failure != nil {
+				return &requestError{http.StatusBadRequest, failure}
+			}
+			created = append(created, rule)
+		}
+		if len(created) == 0 {
+			return nil
+		}
+
+		if err := tx.Create(&created).Error; err != nil {
+			return err
+		}
+		touched := make(map[uint]bool, len(created))
+		for _, rule := range created {
+			touched[rule.ID] = true
+		}
+		return verifyDraftRules(tx, clientID, touched)
+	})
+	return created, skipped, err
+}
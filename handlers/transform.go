@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"bytes"
 	"data_mapping/models"
 	"data_mapping/utils"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
-	"strings"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -14,24 +17,41 @@ import (
 // UnifiedTransformHandler handles both standard and large payloads for transformation.
 func UnifiedTransformHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		clientID := c.Param("client_id")
-		var rules []models.MappingRule
-		result := db.Where("client_id = ?", clientID).Find(&rules)
-		if result.Error != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to load mapping rules",
-				"details": result.Error.Error(),
-			})
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
 			return
 		}
-
-		if len(rules) == 0 {
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
 			c.JSON(http.StatusNotFound, gin.H{
-				"error": "No mapping rules found for this client",
+				"error": "Client not found",
 			})
 			return
 		}
 
+		// Template clients render their template and have no rules to load
+		var rules []models.MappingRule
+		template := client.TransformMode == models.TransformModeTemplate
+		if !template {
+			var version int
+			if rules, version, ok = loadTransformRules(c, db, client); !ok {
+				return
+			}
+			if version > 0 {
+				c.Header("X-Mapping-Version", strconv.Itoa(version))
+			}
+
+			if len(rules) == 0 {
+				c.JSON(http.StatusNotFound, gin.H{
+					"error": "No mapping rules found for this client",
+				})
+				return
+			}
+			if rules, ok = withSubMappings(c, db, rules); !ok {
+				return
+			}
+		}
+
 		// Limit payload size for security (e.g., 10MB)
 		if c.Request.ContentLength > 10*1024*1024 {
 			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
@@ -40,9 +60,48 @@ func UnifiedTransformHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Handle streaming for large payloads
-		stream := c.GetHeader("X-Stream-Transform") == "true"
-		if stream || (c.Request.ContentLength > 5*1024*1024) {
+		enc, ok := outputEncoder(c, client)
+		if !ok {
+			return
+		}
+
+		stream := c.ContentType() != "text/csv" &&
+			(c.GetHeader("X-Stream-Transform") == "true" || c.Request.ContentLength > 5*1024*1024)
+		if template && stream {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "Streaming transformation is not supported for template clients",
+			})
+			return
+		}
+		if stream {
+			if setting := streamingUnsupported(client, enc); setting != "" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Streaming transformation is not supported for this client",
+					"details": "streamed transforms cannot apply the client's " + setting + "; send at most 5MB without X-Stream-Transform",
+				})
+				return
+			}
+		}
+		// Tabular input: each CSV row is routed and transformed as its own record
+		if c.ContentType() == "text/csv" {
+			transformCSV(c, db, client, enc, rules, transformOptions(client))
+			return
+		}
+
+		// Handle streaming for large payloads. Streamed input is not read up
+		// front, so routes cannot see it and the set must come from the header
+		// unless the client has no routes
+		if stream {
+			if len(client.Routing.Routes) > 0 && c.GetHeader("X-Mapping-Set") == "" {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"error":   "Streamed transforms need X-Mapping-Set for clients with routes",
+					"details": "routes cannot see streamed input; name the mapping set in the X-Mapping-Set header",
+				})
+				return
+			}
+			if rules, ok = routeRules(c, models.Routing{Default: client.Routing.Default}, nil, rules); !ok {
+				return
+			}
 			c.Writer.Header().Set("Content-Type", "application/json")
 			if err := utils.StreamTransformJSONWithRules(c.Request.Body, c.Writer, rules); err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{
@@ -63,10 +122,34 @@ func UnifiedTransformHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		input, unmatched, ok := requestInput(c, request)
+		if !ok {
+			return
+		}
+		if !template {
+			if rules, ok = routeRules(c, client.Routing, input, rules); !ok {
+				return
+			}
+		}
+
+		// Reject input that breaks the client's input schema before any rule runs
+		inputErrs, ok := validateSchemaRef(c, db, client.InputSchema, input)
+		if !ok {
+			return
+		}
+		if len(inputErrs) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":  "Input failed schema validation",
+				"schema": client.InputSchema,
+				"errors": inputErrs,
+			})
+			return
+		}
+
 		// Debug: Log the number of rules and input structure
-		log.Printf("Transform Debug - Client ID: %s, Rules count: %d", clientID, len(rules))
-		inputKeys := make([]string, 0, len(request.InputData))
-		for k := range request.InputData {
+		log.Printf("Transform Debug - Client ID: %d, Rules count: %d", clientID, len(rules))
+		inputKeys := make([]string, 0, len(input))
+		for k := range input {
 			inputKeys = append(inputKeys, k)
 		}
 		log.Printf("Transform Debug - Input data keys: %v", inputKeys)
@@ -74,62 +157,316 @@ func UnifiedTransformHandler(db *gorm.DB) gin.HandlerFunc {
 			log.Printf("Rule %d: %v -> %v (%s)", i, rule.SourcePath, rule.DestinationPath, rule.TransformType)
 		}
 
-		output, err := utils.Transform(request.InputData, rules)
+		opts := transformOptions(client)
+		opts.Explain = c.Query("explain") == "true"
+		documents, traces, err := utils.TransformDocuments(input, rules, opts)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Transformation failed",
-				"details": err.Error(),
-			})
+			respondTransformError(c, err)
 			return
 		}
 
-		// Validate that all required fields are present
-		var missingFields []string
-		for _, rule := range rules {
-			if rule.Required {
-				// Check if the output has applicants array
-				if applicants, ok := output["applicants"].([]interface{}); ok {
-					// Check the first applicant (assuming all applicants have the same structure)
-					if len(applicants) > 0 {
-						if applicant, ok := applicants[0].(map[string]interface{}); ok {
-							if _, exists := utils.GetNestedValue(applicant, rule.DestinationPath); !exists {
-								path := strings.Join(rule.DestinationPath, ".")
-								missingFields = append(missingFields, path)
-							}
-						}
-					}
-				} else {
-					// Single object output
-					if _, exists := utils.GetNestedValue(output, rule.DestinationPath); !exists {
-						path := strings.Join(rule.DestinationPath, ".")
-						missingFields = append(missingFields, path)
-					}
-				}
+		// Check required fields and rule constraints anywhere in the output
+		violations, warnings := validateDocuments(documents, rules, opts)
+		if respondStrictViolations(c, opts, violations, len(violations)) {
+			return
+		}
+		outputErrs, ok := validateDocumentSchemas(c, db, client.OutputSchema, documents, opts)
+		if !ok {
+			return
+		}
+		if len(outputErrs) > 0 {
+			if strictValidation(c, opts) {
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":  "Output failed schema validation",
+					"schema": client.OutputSchema,
+					"errors": outputErrs,
+				})
+				return
+			}
+			if warnings == nil {
+				warnings = gin.H{}
 			}
+			warnings["outputSchemaErrors"] = outputErrs
 		}
-
-		// Remove duplicate entries from missingFields
-		seen := make(map[string]bool)
-		unique := make([]string, 0, len(missingFields))
-		for _, field := range missingFields {
-			if !seen[field] {
-				seen[field] = true
-				unique = append(unique, field)
+		if c.Query("coverage") == "true" {
+			if warnings == nil {
+				warnings = gin.H{}
+			}
+			warnings["coverage"] = utils.AnalyzeCoverage(input, rules)
+		}
+		if len(unmatched) > 0 {
+			if warnings == nil {
+				warnings = gin.H{}
 			}
+			warnings["unmatchedRecords"] = unmatched
+		}
+
+		writeTransformResult(c, enc, documentsResult(documents, opts), warnings, explainResult(traces, opts))
+	}
+}
+
+// requestInput returns the input tree of a transform request. Named documents
+// are merged, and joined when the request has a join spec, in which case the
+// records that failed to join are returned too. It writes the error response
+// itself.
+func requestInput(c *gin.Context, request models.TransformationRequest) (map[string]interface{}, []utils.UnmatchedRecord, bool) {
+	if request.Documents == nil {
+		if request.Join != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "join requires documents"})
+			return nil, nil, false
+		}
+		return request.InputData, nil, true
+	}
+	input, unmatched, err := utils.MergeDocuments(request.Documents, request.Join)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid join",
+			"details": err.Error(),
+		})
+		return nil, nil, false
+	}
+	return input, unmatched, true
+}
+
+// routeRules narrows rules to the mapping set for the request: the set named
+// by the X-Mapping-Set header, else the one routing picks for input. A named
+// set is reported in the X-Mapping-Set response header. It writes the error
+// response itself.
+func routeRules(c *gin.Context, routing models.Routing, input map[string]interface{}, rules []models.MappingRule) ([]models.MappingRule, bool) {
+	override := c.GetHeader("X-Mapping-Set")
+	set, selected, err := utils.RouteMappingSet(input, rules, routing, override)
+	if err != nil {
+		status := http.StatusUnprocessableEntity
+		if override != "" {
+			status = http.StatusBadRequest
 		}
-		missingFields = unique
+		c.JSON(status, gin.H{
+			"error":   "Could not select a mapping set",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	if set != "" {
+		c.Header("X-Mapping-Set", set)
+	}
+	return selected, true
+}
+
+// streamingUnsupported names the client setting a streamed transform cannot
+// apply, or returns "" when the client can be streamed. Streaming maps each
+// top-level input member on its own and always writes JSON.
+func streamingUnsupported(client models.Client, enc utils.Encoder) string {
+	switch {
+	case client.SplitOn != "":
+		return "split_on"
+	case len(client.Pipeline.Stages) > 0:
+		return "pipeline"
+	case client.PassThrough.Enabled:
+		return "pass_through"
+	case client.InputSchema != "" || client.OutputSchema != "":
+		return "schemas"
+	}
+	if _, ok := enc.(utils.JSONEncoder); !ok {
+		return "output format"
+	}
+	return ""
+}
+
+// respondTransformError reports a failed transform: 422 when the input
+// cannot be split as the client requires or its template cannot be rendered
+// from the input, 500 otherwise.
+func respondTransformError(c *gin.Context, err error) {
+	status := http.StatusInternalServerError
+	if errors.Is(err, utils.ErrSplitInput) || errors.Is(err, utils.ErrTemplateRender) {
+		status = http.StatusUnprocessableEntity
+	}
+	c.JSON(status, gin.H{
+		"error":   "Transformation failed",
+		"details": err.Error(),
+	})
+}
+
+// documentsResult is the response data for transformed documents: the array
+// of documents for clients that split their input, else the one document.
+func documentsResult(documents []map[string]interface{}, opts utils.TransformOptions) interface{} {
+	if len(opts.SplitOn) == 0 {
+		return documents[0]
+	}
+	list := make([]interface{}, len(documents))
+	for i, document := range documents {
+		list[i] = document
+	}
+	return list
+}
+
+// explainResult is the response trace for transformed documents, shaped like
+// documentsResult. It is nil unless explain=true was asked for.
+func explainResult(traces [][]utils.StageTrace, opts utils.TransformOptions) interface{} {
+	if !opts.Explain {
+		return nil
+	}
+	if len(opts.SplitOn) == 0 {
+		return traces[0]
+	}
+	return traces
+}
+
+// transformOptions collects the client settings applied around its rules,
+// including the template of template clients.
+func transformOptions(client models.Client) utils.TransformOptions {
+	opts := utils.TransformOptions{
+		PassThrough: client.PassThrough,
+		SplitOn:     models.ParsePath(client.SplitOn),
+		Pipeline:    client.Pipeline,
+	}
+	if client.TransformMode == models.TransformModeTemplate {
+		opts.Template, _ = client.Template.Data.(map[string]interface{})
+	}
+	return opts
+}
+
+// outputEncoder picks the response encoder from the Accept header, falling
+// back to the client's configured output format. A format the client cannot
+// produce is 406 when the Accept header asked for it and 500 when it is the
+// stored setting. It writes the error response itself.
+func outputEncoder(c *gin.Context, client models.Client) (utils.Encoder, bool) {
+	if format := utils.FormatFromAccept(c.GetHeader("Accept")); format != "" {
+		enc, err := utils.NewEncoder(format, client.OutputOptions)
+		if err != nil {
+			c.JSON(http.StatusNotAcceptable, gin.H{
+				"error":   "Requested output format is not available for this client",
+				"details": err.Error(),
+			})
+			return nil, false
+		}
+		return enc, true
+	}
+	enc, err := utils.NewEncoder(client.OutputFormat, client.OutputOptions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Invalid output format configured for client",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	return enc, true
+}
 
+// writeTransformResult writes transformed data with the selected encoder. JSON
+// responses keep the success/data/warnings envelope, with the pipeline trace
+// under explain when there is one; other formats carry only the data and
+// report warnings in the X-Transform-Warnings header.
+func writeTransformResult(c *gin.Context, enc utils.Encoder, data interface{}, warnings gin.H, explain interface{}) {
+	if _, ok := enc.(utils.JSONEncoder); ok {
 		response := gin.H{
 			"success": true,
-			"data":    output,
+			"data":    data,
 		}
+		if len(warnings) > 0 {
+			response["warnings"] = warnings
+		}
+		if explain != nil {
+			response["explain"] = explain
+		}
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := enc.Encode(&buf, data); err != nil {
+		if errors.Is(err, utils.ErrFixedWidthOverflow) {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Output does not fit the fixed-width layout",
+				"details": err.Error(),
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to encode output",
+			"details": err.Error(),
+		})
+		return
+	}
+	if reporter, ok := enc.(utils.WarningReporter); ok && len(reporter.Warnings()) > 0 {
+		if warnings == nil {
+			warnings = gin.H{}
+		}
+		warnings["encoding"] = reporter.Warnings()
+	}
+	if len(warnings) > 0 {
+		if header, err := json.Marshal(warnings); err == nil {
+			c.Header("X-Transform-Warnings", string(header))
+		}
+	}
+	c.Data(http.StatusOK, enc.ContentType(), buf.Bytes())
+}
 
-		if len(missingFields) > 0 {
-			response["warnings"] = gin.H{
-				"missingRequiredFields": missingFields,
+// validateOutput checks output against the rules' required flags and
+// constraints. It returns the violations and the warnings reporting them:
+// missing required paths under missingRequiredFields and everything else
+// under constraintViolations. Warnings are nil when the output is valid.
+func validateOutput(output map[string]interface{}, rules []models.MappingRule) ([]utils.ConstraintViolation, gin.H) {
+	violations := utils.ValidateOutput(output, rules)
+	return violations, violationWarnings(violations)
+}
+
+// validateDocuments is validateOutput for every transformed document. When
+// the input was split, violation paths start with the document's index.
+// When the client pipeline skips validation nothing is checked and the
+// warnings say so under validationSkipped.
+func validateDocuments(documents []map[string]interface{}, rules []models.MappingRule, opts utils.TransformOptions) ([]utils.ConstraintViolation, gin.H) {
+	if !opts.Pipeline.Validates() {
+		return nil, gin.H{"validationSkipped": true}
+	}
+	var violations []utils.ConstraintViolation
+	for i, document := range documents {
+		found := utils.ValidateOutput(document, rules)
+		if len(opts.SplitOn) > 0 {
+			for j := range found {
+				found[j].Path = strconv.Itoa(i) + "." + found[j].Path
 			}
 		}
+		violations = append(violations, found...)
+	}
+	return violations, violationWarnings(violations)
+}
 
-		c.JSON(http.StatusOK, response)
+// violationWarnings groups violations into the transform warnings.
+func violationWarnings(violations []utils.ConstraintViolation) gin.H {
+	if len(violations) == 0 {
+		return nil
+	}
+	warnings := gin.H{}
+	if missing := utils.MissingRequiredPaths(violations); len(missing) > 0 {
+		warnings["missingRequiredFields"] = missing
+	}
+	var others []utils.ConstraintViolation
+	for _, v := range violations {
+		if v.Constraint != "required" {
+			others = append(others, v)
+		}
+	}
+	if len(others) > 0 {
+		warnings["constraintViolations"] = others
+	}
+	return warnings
+}
+
+// strictValidation reports whether invalid output is rejected: when
+// strict=true was asked for or the client's validate stage is strict.
+func strictValidation(c *gin.Context, opts utils.TransformOptions) bool {
+	return c.Query("strict") == "true" || opts.Pipeline.Strict()
+}
+
+// respondStrictViolations rejects the request with 422 when validation is
+// strict and the output has violations. It reports whether it responded.
+func respondStrictViolations(c *gin.Context, opts utils.TransformOptions, violations interface{}, count int) bool {
+	if !strictValidation(c, opts) || count == 0 {
+		return false
 	}
+	c.JSON(http.StatusUnprocessableEntity, gin.H{
+		"error":      "Output failed validation",
+		"violations": violations,
+	})
+	return true
 }
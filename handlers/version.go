@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"data_mapping/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// versionSummary is a mapping set version without its rule snapshot.
+type versionSummary struct {
+	Version        int       `json:"version"`
+	Comment        string    `json:"comment"`
+	RolledBackFrom int       `json:"rolled_back_from,omitempty"`
+	RuleCount      int       `json:"rule_count"`
+	Active         bool      `json:"active" gorm:"-"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+// PublishMappings snapshots the client's draft mapping rules as a new version
+// and makes it the one used by transforms.
+func PublishMappings(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		var req models.PublishVersionRequest
+		if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":   "Invalid request body",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		rules, err := loadDraftRules(db, client.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if len(rules) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No mapping rules to publish"})
+			return
+		}
+		// The version keeps the sub-mappings as they are now, so later edits
+		// to them need a new publish to go live
+		if rules, ok = withSubMappings(c, db, rules); !ok {
+			return
+		}
+		if client.ActiveVersion > 0 {
+			active, err := findVersion(db, client.ID, client.ActiveVersion)
+			if err != nil {
+				respondVersionError(c, err)
+				return
+			}
+			if diff := utils.DiffRuleSets(active.Rules, rules); !diff.HasChanges() {
+				c.JSON(http.StatusConflict, gin.H{
+					"error": fmt.Sprintf("Draft has no changes since version %d", client.ActiveVersion),
+				})
+				return
+			}
+		}
+
+		if client.RequirePassingFixtures {
+			report, err := runClientFixtures(db, client, rules)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if report.Failed > 0 {
+				report.Target = "draft"
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":    "Publish blocked: " + fixtureFailureSummary(report),
+					"fixtures": report,
+				})
+				return
+			}
+		}
+
+		version, err := publishVersion(db, client.ID, rules, req.Comment, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to publish mapping rules",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"data":    version,
+		})
+	}
+}
+
+func ListVersions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		var versions []versionSummary
+		result := db.Model(&models.MappingSetVersion{}).
+			Select("version, comment, rolled_back_from, created_at, jsonb_array_length(rules) AS rule_count").
+			Where("client_id = ?", client.ID).
+			Order("version DESC").
+			Scan(&versions)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": result.Error.Error()})
+			return
+		}
+		for i := range versions {
+			versions[i].Active = versions[i].Version == client.ActiveVersion
+		}
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+func GetVersion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+		snapshot, err := findVersion(db, clientID, version)
+		if err != nil {
+			respondVersionError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, snapshot)
+	}
+}
+
+// DiffVersions compares the rules of two versions given by the from and to
+// query parameters. The to version defaults to the active one.
+func DiffVersions(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		var client models.Client
+		if result := db.First(&client, clientID); result.Error != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Client not found"})
+			return
+		}
+
+		from, err := strconv.Atoi(c.Query("from"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'from' must be a version number"})
+			return
+		}
+		to := client.ActiveVersion
+		if raw := c.Query("to"); raw != "" {
+			if to, err = strconv.Atoi(raw); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'to' must be a version number"})
+				return
+			}
+		}
+
+		fromVersion, err := findVersion(db, client.ID, from)
+		if err != nil {
+			respondVersionError(c, err)
+			return
+		}
+		toVersion, err := findVersion(db, client.ID, to)
+		if err != nil {
+			respondVersionError(c, err)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from": from,
+			"to":   to,
+			"diff": utils.DiffRuleSets(fromVersion.Rules, toVersion.Rules),
+		})
+	}
+}
+
+// RollbackVersion republishes the rules of an earlier version as a new
+// version and makes it active. The draft rules are not changed. Clients that
+// require passing fixtures must pass them with the earlier rules.
+func RollbackVersion(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientID, ok := parseID(c, "client_id")
+		if !ok {
+			return
+		}
+		target, err := strconv.Atoi(c.Param("version"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+		snapshot, err := findVersion(db, clientID, target)
+		if err != nil {
+			respondVersionError(c, err)
+			return
+		}
+
+		var client models.Client
+		if err := db.First(&client, snapshot.ClientID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if client.RequirePassingFixtures {
+			report, err := runClientFixtures(db, client, snapshot.Rules)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			if report.Failed > 0 {
+				report.Target = "published"
+				report.Version = target
+				c.JSON(http.StatusUnprocessableEntity, gin.H{
+					"error":    "Rollback blocked: " + fixtureFailureSummary(report),
+					"fixtures": report,
+				})
+				return
+			}
+		}
+
+		comment := fmt.Sprintf("Rollback to version %d", target)
+		version, err := publishVersion(db, snapshot.ClientID, snapshot.Rules, comment, target)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to roll back mapping rules",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"success": true,
+			"data":    version,
+		})
+	}
+}
+
+// publishVersion stores rules as the client's next version and activates it.
+// The client row is locked so concurrent publishes get consecutive numbers.
+func publishVersion(db *gorm.DB, clientID uint, rules []models.MappingRule, comment string, rolledBackFrom int) (models.MappingSetVersion, error) {
+	var version models.MappingSetVersion
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var client models.Client
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&client, clientID).Error; err != nil {
+			return err
+		}
+
+		var latest int
+		if err := tx.Model(&models.MappingSetVersion{}).
+			Where("client_id = ?", clientID).
+			Select("COALESCE(MAX(version), 0)").
+			Scan(&latest).Error; err != nil {
+			return err
+		}
+
+		version = models.MappingSetVersion{
+			ClientID:       clientID,
+			Version:        latest + 1,
+			Rules:          rules,
+			Comment:        comment,
+			RolledBackFrom: rolledBackFrom,
+		}
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		return tx.Model(&client).Update("active_version", version.Version).Error
+	})
+	return version, err
+}
+
+func findVersion(db *gorm.DB, clientID uint, version int) (models.MappingSetVersion, error) {
+	var snapshot models.MappingSetVersion
+	err := db.Where("client_id = ? AND version = ?", clientID, version).First(&snapshot).Error
+	return snapshot, err
+}
+
+func respondVersionError(c *gin.Context, err error) {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mapping version not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// loadTransformRules resolves the published rules a transform runs with: the
+// version pinned by the X-Mapping-Version header or version query parameter,
+// else the client's active version. Draft rules are never used. It writes the
+// error response itself and reports whether the caller may continue.
+func loadTransformRules(c *gin.Context, db *gorm.DB, client models.Client) ([]models.MappingRule, int, bool) {
+	pinned := c.GetHeader("X-Mapping-Version")
+	if pinned == "" {
+		pinned = c.Query("version")
+	}
+
+	version := client.ActiveVersion
+	if pinned != "" {
+		n, err := strconv.Atoi(pinned)
+		if err != nil || n <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid mapping version"})
+			return nil, 0, false
+		}
+		version = n
+	}
+
+	if version == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "No published mapping rules for this client. Publish the draft first.",
+		})
+		return nil, 0, false
+	}
+
+	snapshot, err := findVersion(db, client.ID, version)
+	if err != nil {
+		respondVersionError(c, err)
+		return nil, 0, false
+	}
+	return snapshot.Rules, version, true
+}
+
+// loadTargetRules loads the rules named by the target query parameter: the
+// draft for target=draft, otherwise the published rules as resolved by
+// loadTransformRules. It writes the error response itself.
+func loadTargetRules(c *gin.Context, db *gorm.DB, client models.Client) ([]models.MappingRule, string, int, bool) {
+	target := c.DefaultQuery("target", "published")
+	switch target {
+	case "draft":
+		rules, err := loadDraftRules(db, client.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return nil, "", 0, false
+		}
+		return rules, target, 0, true
+	case "published":
+		rules, version, ok := loadTransformRules(c, db, client)
+		return rules, target, version, ok
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target must be 'draft' or 'published'"})
+		return nil, "", 0, false
+	}
+}
@@ -0,0 +1,34 @@
+package handlers
+
+import (
+	"data_mapping/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Transforms never fall back to the draft: a client without a published
+// version, or a malformed pin, is answered before the database is read.
+func TestLoadTransformRulesWithoutPublishedVersion(t *testing.T) {
+	for _, tc := range []struct {
+		pin  string
+		want int
+	}{
+		{"", http.StatusNotFound},
+		{"abc", http.StatusBadRequest},
+		{"0", http.StatusBadRequest},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodPost, "/clients/1/transform", nil)
+		if tc.pin != "" {
+			c.Request.Header.Set("X-Mapping-Version", tc.pin)
+		}
+
+		if _, _, ok := loadTransformRules(c, nil, models.Client{ID: 1}); ok || w.Code != tc.want {
+			t.Errorf("pin %q: ok = %v, status %d; want %d", tc.pin, ok, w.Code, tc.want)
+		}
+	}
+}
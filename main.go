@@ -52,11 +52,57 @@ func main() {
 		// Client management
 		auth.POST("/clients", handlers.CreateClient(database.DB))
 		auth.GET("/clients", handlers.ListClients(database.DB))
+		auth.PATCH("/clients/:id", handlers.UpdateClient(database.DB))
 		auth.DELETE("/clients/:id", handlers.DeleteClient(database.DB))
 		auth.POST("/clients/:client_id/mappings", handlers.CreateMappings(database.DB))
 		auth.GET("/clients/:client_id/mappings", handlers.GetMappings(database.DB))
+		auth.PUT("/clients/:client_id/mappings", handlers.ReplaceMappings(database.DB))
+		auth.POST("/clients/:client_id/mappings/import", handlers.ImportMappings(database.DB))
+		auth.GET("/clients/:client_id/mappings/export", handlers.ExportMappings(database.DB))
+		auth.GET("/clients/:client_id/mappings/conflicts", handlers.GetMappingConflicts(database.DB))
+		auth.POST("/clients/:client_id/mappings/suggest", handlers.SuggestMappings(database.DB))
+		auth.GET("/mappings/:mapping_id", handlers.GetMapping(database.DB))
+		auth.PUT("/mappings/:mapping_id", handlers.UpdateMapping(database.DB))
+		auth.PATCH("/mappings/:mapping_id", handlers.PatchMapping(database.DB))
 		auth.DELETE("/mappings/:mapping_id", handlers.DeleteMappings(database.DB))
 
+		// Draft workspace
+		auth.GET("/clients/:client_id/draft", handlers.GetDraft(database.DB))
+		auth.POST("/clients/:client_id/draft/preview", handlers.PreviewDraft(database.DB))
+		auth.POST("/clients/:client_id/draft/discard", handlers.DiscardDraft(database.DB))
+
+		// Golden fixtures
+		auth.POST("/clients/:client_id/fixtures", handlers.CreateFixture(database.DB))
+		auth.GET("/clients/:client_id/fixtures", handlers.ListFixtures(database.DB))
+		auth.POST("/clients/:client_id/fixtures/run", handlers.RunFixtures(database.DB))
+		auth.DELETE("/fixtures/:fixture_id", handlers.DeleteFixture(database.DB))
+
+		// JSON Schemas
+		auth.POST("/schemas", handlers.CreateSchema(database.DB))
+		auth.GET("/schemas", handlers.ListSchemas(database.DB))
+		auth.GET("/schemas/:name", handlers.GetSchema(database.DB))
+		auth.PUT("/schemas/:name", handlers.UpdateSchema(database.DB))
+		auth.DELETE("/schemas/:name", handlers.DeleteSchema(database.DB))
+
+		// Reusable sub-mappings
+		auth.POST("/sub-mappings", handlers.CreateSubMapping(database.DB))
+		auth.GET("/sub-mappings", handlers.ListSubMappings(database.DB))
+		auth.GET("/sub-mappings/:name", handlers.GetSubMapping(database.DB))
+		auth.PUT("/sub-mappings/:name", handlers.UpdateSubMapping(database.DB))
+		auth.DELETE("/sub-mappings/:name", handlers.DeleteSubMapping(database.DB))
+
+		// Analysis
+		auth.GET("/clients/:client_id/schema/output", handlers.GetOutputSchema(database.DB))
+		auth.GET("/clients/:client_id/schema/input", handlers.GetInputSchema(database.DB))
+		auth.POST("/clients/:client_id/analysis/coverage", handlers.AnalyzeCoverage(database.DB))
+
+		// Mapping set versions
+		auth.POST("/clients/:client_id/versions", handlers.PublishMappings(database.DB))
+		auth.GET("/clients/:client_id/versions", handlers.ListVersions(database.DB))
+		auth.GET("/clients/:client_id/versions/diff", handlers.DiffVersions(database.DB))
+		auth.GET("/clients/:client_id/versions/:version", handlers.GetVersion(database.DB))
+		auth.POST("/clients/:client_id/versions/:version/rollback", handlers.RollbackVersion(database.DB))
+
 		auth.POST("/clients/:client_id/transform", handlers.UnifiedTransformHandler(database.DB))
 	}
 
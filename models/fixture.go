@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Fixture is a named sample input and the output a client's mapping rules are
+// expected to produce for it.
+type Fixture struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	ClientID  uint         `gorm:"not null;uniqueIndex:idx_client_fixture" json:"client_id"`
+	Client    Client       `gorm:"foreignKey:ClientID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-" validate:"-"`
+	Name      string       `gorm:"size:100;not null;uniqueIndex:idx_client_fixture" json:"name"`
+	Input     JSONDocument `gorm:"type:jsonb;not null" json:"input"`
+	Expected  JSONDocument `gorm:"type:jsonb;not null" json:"expected"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type CreateFixtureRequest struct {
+	Name     string                 `json:"name" binding:"required" validate:"required,min=1,max=100"`
+	Input    map[string]interface{} `json:"input" binding:"required"`
+	Expected interface{}            `json:"expected" binding:"required"`
+}
@@ -4,32 +4,193 @@ import (
 	"database/sql/driver"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
 type Client struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `gorm:"unique;not null" json:"name" validate:"required,min=1,max=100"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID                     uint          `gorm:"primaryKey" json:"id"`
+	Name                   string        `gorm:"unique;not null" json:"name" validate:"required,min=1,max=100"`
+	OutputFormat           string        `gorm:"size:20;default:json" json:"output_format"`
+	OutputOptions          OutputOptions `gorm:"type:jsonb" json:"output_options"`
+	PassThrough            PassThrough   `gorm:"type:jsonb" json:"pass_through"`
+	InputSchema            string        `gorm:"size:100" json:"input_schema"`
+	OutputSchema           string        `gorm:"size:100" json:"output_schema"`
+	SplitOn                string        `gorm:"size:255" json:"split_on"`
+	Routing                Routing       `gorm:"type:jsonb" json:"routing"`
+	Pipeline               Pipeline      `gorm:"type:jsonb" json:"pipeline"`
+	TransformMode          string        `gorm:"size:20;default:rules" json:"transform_mode"`
+	Template               JSONDocument  `gorm:"type:jsonb" json:"template"`
+	ActiveVersion          int           `gorm:"default:0" json:"active_version"`
+	RequirePassingFixtures bool          `gorm:"default:false" json:"require_passing_fixtures"`
+	CreatedAt              time.Time     `json:"created_at"`
+	UpdatedAt              time.Time     `json:"updated_at"`
+}
+
+// TransformModeTemplate is the Client.TransformMode of clients whose output
+// is rendered from their template instead of built by their rules.
+const TransformModeTemplate = "template"
+
+// OutputOptions configures the serializers used for a client's transform responses.
+type OutputOptions struct {
+	XMLRootName  string            `json:"xml_root_name,omitempty"`
+	XMLItemName  string            `json:"xml_item_name,omitempty"`
+	CSVDelimiter string            `json:"csv_delimiter,omitempty"`
+	FixedWidth   *FixedWidthLayout `json:"fixed_width,omitempty"`
+}
+
+// FixedWidthLayout describes the fixed-width text record written for each
+// output document.
+type FixedWidthLayout struct {
+	Fields          []FixedWidthField `json:"fields"`
+	RecordSeparator string            `json:"record_separator,omitempty"` // Defaults to "\n"
+}
+
+// FixedWidthField maps a dotted destination path to a column of the record.
+type FixedWidthField struct {
+	Path    string `json:"path"`
+	Width   int    `json:"width"`
+	PadChar string `json:"pad_char,omitempty"` // Defaults to a space
+	Align   string `json:"align,omitempty"`    // "left" (default) or "right"
+	ZeroPad bool   `json:"zero_pad,omitempty"` // Right-align numbers padded with zeros after the sign
+}
+
+func (o *OutputOptions) Scan(value interface{}) error {
+	return scanJSON(value, o)
+}
+
+func (o OutputOptions) Value() (driver.Value, error) {
+	return json.Marshal(o)
+}
+
+// PassThrough copies input fields that no rule reads into the output, so a
+// client can keep everything as-is apart from its explicit rules. Source
+// limits copying to one input subtree and Target places the copied fields
+// under an output prefix; both are dotted paths and empty means the root.
+// Exclude patterns are dotted paths where "*" matches one key and "**" any
+// number of keys; a pattern without dots matches that key at any depth.
+type PassThrough struct {
+	Enabled bool     `json:"enabled"`
+	Source  string   `json:"source,omitempty"`
+	Target  string   `json:"target,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+func (p *PassThrough) Scan(value interface{}) error {
+	return scanJSON(value, p)
+}
+
+func (p PassThrough) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
+
+// Routing picks the mapping set a transform uses when a client's rules are
+// split into named sets. Routes are tried in order and the first whose When
+// expression holds for the input wins; Default names the set used when none
+// does. The empty name is the set of rules without a set.
+type Routing struct {
+	Routes  []Route `json:"routes,omitempty"`
+	Default string  `json:"default,omitempty"`
+}
+
+// Route selects Set when its When expression evaluates to true, with the
+// request input available as input.
+type Route struct {
+	Set  string `json:"set"`
+	When string `json:"when"`
+}
+
+func (r *Routing) Scan(value interface{}) error {
+	return scanJSON(value, r)
+}
+
+func (r Routing) Value() (driver.Value, error) {
+	return json.Marshal(r)
 }
 
 type MappingRule struct {
-	ID              uint           `gorm:"primaryKey" json:"id"`
-	ClientID        uint           `gorm:"not null" json:"client_id"`
-	Client          Client         `gorm:"foreignKey:ClientID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-" validate:"-"`
-	SourcePath      JSONStringList `gorm:"type:jsonb;not null" json:"source_path" validate:"required,min=1"`
-	DestinationPath JSONStringList `gorm:"type:jsonb;not null" json:"destination_path" validate:"required,min=1"`
-	TransformType   string         `gorm:"not null" json:"transform_type" validate:"required,oneof=copy toString mapGender toBool formatDate toUpperCase toLowerCase capitalize expression"`
-	TransformLogic  string         `gorm:"type:text" json:"transform_logic"`
-	Required        bool           `gorm:"default:false" json:"required"`
-	DefaultValue    string         `gorm:"type:text" json:"default_value"`
-	CreatedAt       time.Time      `json:"created_at"`
-	UpdatedAt       time.Time      `json:"updated_at"`
+	ID              uint            `gorm:"primaryKey" json:"id"`
+	ClientID        uint            `gorm:"not null" json:"client_id"`
+	Client          Client          `gorm:"foreignKey:ClientID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-" validate:"-"`
+	Set             string          `gorm:"column:set_name;size:100;not null;default:''" json:"set,omitempty" validate:"max=100"`
+	SourcePath      JSONStringList  `gorm:"type:jsonb;not null" json:"source_path" validate:"required,min=1"`
+	DestinationPath JSONStringList  `gorm:"type:jsonb;not null" json:"destination_path" validate:"required,min=1"`
+	TransformType   string          `gorm:"not null" json:"transform_type" validate:"required,oneof=copy toString mapGender toBool formatDate toUpperCase toLowerCase capitalize expression group ungroup subMapping"`
+	TransformLogic  string          `gorm:"type:text" json:"transform_logic"`
+	Required        bool            `gorm:"default:false" json:"required"`
+	DefaultValue    string          `gorm:"type:text" json:"default_value"`
+	AllowOverride   bool            `gorm:"default:false" json:"allow_override"`
+	Constraints     *Constraints    `gorm:"type:jsonb" json:"constraints,omitempty"`
+	SubRules        MappingRuleList `gorm:"type:jsonb" json:"sub_rules,omitempty" validate:"-"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+}
+
+// Constraints are checked against the value a rule wrote once the whole
+// output is built. Type is a JSON type name ("string", "number", "integer",
+// "boolean", "object", "array" or "null"); Min and Max bound numbers and
+// MinLength and MaxLength bound the length of strings and arrays.
+type Constraints struct {
+	Type      string        `json:"type,omitempty"`
+	Pattern   string        `json:"pattern,omitempty"`
+	Enum      []interface{} `json:"enum,omitempty"`
+	Min       *float64      `json:"min,omitempty"`
+	Max       *float64      `json:"max,omitempty"`
+	MinLength *int          `json:"min_length,omitempty"`
+	MaxLength *int          `json:"max_length,omitempty"`
+}
+
+func (c *Constraints) Scan(value interface{}) error {
+	return scanJSON(value, c)
+}
+
+func (c Constraints) Value() (driver.Value, error) {
+	return json.Marshal(c)
 }
 
+// JSONStringList is a path stored as a JSON array of keys. It also accepts a
+// dotted string such as "applicantDetails.0.mobileNo" when decoding JSON. A
+// JSONPath expression is kept whole as the only element of the list.
 type JSONStringList []string
 
+// IsJSONPathString reports whether a path string uses JSONPath syntax: it
+// starts with '$' or contains '[' or "..".
+func IsJSONPathString(path string) bool {
+	return strings.HasPrefix(path, "$") || strings.Contains(path, "[") || strings.Contains(path, "..")
+}
+
+// IsJSONPath reports whether path holds a single JSONPath expression rather
+// than a list of keys.
+func IsJSONPath(path []string) bool {
+	return len(path) == 1 && IsJSONPathString(path[0])
+}
+
+// ParsePath splits a dotted path into its keys.
+func ParsePath(path string) JSONStringList {
+	if path == "" {
+		return JSONStringList{}
+	}
+	return strings.Split(path, ".")
+}
+
+func (j *JSONStringList) UnmarshalJSON(data []byte) error {
+	var dotted string
+	if err := json.Unmarshal(data, &dotted); err == nil {
+		if IsJSONPathString(dotted) {
+			*j = JSONStringList{dotted}
+			return nil
+		}
+		*j = ParsePath(dotted)
+		return nil
+	}
+	var keys []string
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return fmt.Errorf("path must be a dotted string or an array of strings")
+	}
+	*j = keys
+	return nil
+}
+
 func (j *JSONStringList) Scan(value interface{}) error {
 	bytes, ok := value.([]byte)
 	if !ok {
@@ -41,3 +202,52 @@ func (j *JSONStringList) Scan(value interface{}) error {
 func (j JSONStringList) Value() (driver.Value, error) {
 	return json.Marshal(j)
 }
+
+// MappingRuleList stores a snapshot of mapping rules in a single JSONB column.
+type MappingRuleList []MappingRule
+
+func (l *MappingRuleList) Scan(value interface{}) error {
+	return scanJSON(value, l)
+}
+
+func (l MappingRuleList) Value() (driver.Value, error) {
+	if l == nil {
+		return json.Marshal([]MappingRule{})
+	}
+	return json.Marshal([]MappingRule(l))
+}
+
+// JSONDocument stores an arbitrary JSON value in a JSONB column.
+type JSONDocument struct {
+	Data interface{}
+}
+
+func (d JSONDocument) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.Data)
+}
+
+func (d *JSONDocument) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &d.Data)
+}
+
+func (d *JSONDocument) Scan(value interface{}) error {
+	return scanJSON(value, &d.Data)
+}
+
+func (d JSONDocument) Value() (driver.Value, error) {
+	return json.Marshal(d.Data)
+}
+
+// scanJSON decodes a JSONB column into dest, leaving dest untouched for NULL.
+func scanJSON(value interface{}, dest interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case []byte:
+		return json.Unmarshal(v, dest)
+	case string:
+		return json.Unmarshal([]byte(v), dest)
+	default:
+		return fmt.Errorf("failed to unmarshal JSONB value")
+	}
+}
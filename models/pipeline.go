@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+)
+
+// Pipeline lists the stages of a client's transform in order. It has exactly
+// one map stage, which applies the mapping rules, or renders the template,
+// and applies pass-through. Normalize stages come before it and work on the
+// input; postprocess stages come after it and work on the output, followed
+// by at most one validate stage. A pipeline without a validate stage ends
+// with a default one, so an empty pipeline is a map stage followed by a
+// validate stage.
+type Pipeline struct {
+	Stages []PipelineStage `json:"stages,omitempty"`
+}
+
+// PipelineStage is one step of a pipeline. Type is "normalize", "map",
+// "postprocess" or "validate", and only the options of that type may be set.
+type PipelineStage struct {
+	Type string `json:"type"`
+
+	// normalize: Trim strips surrounding whitespace from strings, and strings
+	// equal to one of Placeholders, ignoring surrounding whitespace, become
+	// null.
+	Trim         bool     `json:"trim,omitempty"`
+	Placeholders []string `json:"placeholders,omitempty"`
+
+	// postprocess: RemoveNulls drops null object members, then MergePatch is
+	// applied to the output as an RFC 7386 JSON merge patch. There is no
+	// option to sort keys: encoding/json already writes map keys sorted, and
+	// the other encoders order keys the same way.
+	RemoveNulls bool                   `json:"remove_nulls,omitempty"`
+	MergePatch  map[string]interface{} `json:"merge_patch,omitempty"`
+
+	// validate: Strict rejects output that fails validation, as strict=true
+	// does for a single request. Skip turns output validation off.
+	Strict bool `json:"strict,omitempty"`
+	Skip   bool `json:"skip,omitempty"`
+}
+
+// Validates reports whether output is validated, which it is unless the
+// validate stage says to skip it.
+func (p Pipeline) Validates() bool {
+	for _, stage := range p.Stages {
+		if stage.Type == "validate" && stage.Skip {
+			return false
+		}
+	}
+	return true
+}
+
+// Strict reports whether the pipeline's validate stage rejects invalid output.
+func (p Pipeline) Strict() bool {
+	for _, stage := range p.Stages {
+		if stage.Type == "validate" && stage.Strict {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Pipeline) Scan(value interface{}) error {
+	return scanJSON(value, p)
+}
+
+func (p Pipeline) Value() (driver.Value, error) {
+	return json.Marshal(p)
+}
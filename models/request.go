@@ -1,9 +1,50 @@
 package models
 
+// TransformationRequest carries the input of a transform: either one
+// document in InputData, or several named Documents that are merged into one
+// input tree, joined on a key when Join is set.
 type TransformationRequest struct {
-	InputData map[string]interface{} `json:"input_data" binding:"required" validate:"required"`
+	InputData map[string]interface{} `json:"input_data" binding:"required_without=Documents" validate:"required_without=Documents"`
+	Documents map[string]interface{} `json:"documents" binding:"excluded_with=InputData"`
+	Join      *JoinSpec              `json:"join"`
+}
+
+// JoinSpec joins the records of named documents to those of the base
+// document. On is the dotted path of the join key within each record; Keys
+// overrides it for documents whose key lives elsewhere.
+type JoinSpec struct {
+	Base string            `json:"base" binding:"required"`
+	On   string            `json:"on" binding:"required"`
+	Keys map[string]string `json:"keys,omitempty"`
+}
+
+// SamplePair is an input document and the output a client expects for it.
+type SamplePair struct {
+	Input  map[string]interface{} `json:"input" binding:"required"`
+	Output map[string]interface{} `json:"output" binding:"required"`
+}
+
+type SuggestMappingsRequest struct {
+	Pairs         []SamplePair `json:"pairs" binding:"required,min=1,dive"`
+	MinConfidence *float64     `json:"min_confidence" validate:"omitempty,min=0,max=1"`
+	SaveToDraft   bool         `json:"save_to_draft"`
 }
 
 type CreateClientRequest struct {
 	Name string `json:"name" binding:"required" validate:"required,min=1,max=100"`
 }
+
+type UpdateClientRequest struct {
+	Name                   *string        `json:"name" validate:"omitempty,min=1,max=100"`
+	OutputFormat           *string        `json:"output_format" validate:"omitempty,oneof=json ndjson xml csv properties fixedwidth"`
+	OutputOptions          *OutputOptions `json:"output_options"`
+	PassThrough            *PassThrough   `json:"pass_through"`
+	InputSchema            *string        `json:"input_schema"`
+	OutputSchema           *string        `json:"output_schema"`
+	SplitOn                *string        `json:"split_on" validate:"omitempty,max=255"`
+	Routing                *Routing       `json:"routing"`
+	Pipeline               *Pipeline      `json:"pipeline"`
+	TransformMode          *string        `json:"transform_mode" validate:"omitempty,oneof=rules template"`
+	Template               *JSONDocument  `json:"template"`
+	RequirePassingFixtures *bool          `json:"require_passing_fixtures"`
+}
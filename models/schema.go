@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// Schema is a stored JSON Schema (draft 2020-12) document. Clients reference
+// schemas by name to validate their transform input and output, and schemas
+// can $ref one another by name or $id.
+type Schema struct {
+	ID        uint         `gorm:"primaryKey" json:"id"`
+	Name      string       `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Document  JSONDocument `gorm:"type:jsonb;not null" json:"document"`
+	CreatedAt time.Time    `json:"created_at"`
+	UpdatedAt time.Time    `json:"updated_at"`
+}
+
+type CreateSchemaRequest struct {
+	Name     string      `json:"name" binding:"required" validate:"required,min=1,max=100,excludesall=#/"`
+	Document interface{} `json:"document" binding:"required"`
+}
+
+type UpdateSchemaRequest struct {
+	Document interface{} `json:"document" binding:"required"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// SubMapping is a named, reusable list of mapping rules. A rule with
+// transform type subMapping names one in its transform_logic and applies it
+// to each element of its source array, so shapes such as an address or a
+// guarantor are defined once and shared across clients.
+type SubMapping struct {
+	ID          uint            `gorm:"primaryKey" json:"id"`
+	Name        string          `gorm:"size:100;uniqueIndex;not null" json:"name"`
+	Description string          `gorm:"type:text" json:"description"`
+	Rules       MappingRuleList `gorm:"type:jsonb;not null" json:"rules"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+}
+
+type CreateSubMappingRequest struct {
+	Name        string        `json:"name" binding:"required" validate:"required,min=1,max=100,excludesall=#/"`
+	Description string        `json:"description"`
+	Rules       []MappingRule `json:"rules" binding:"required" validate:"required,min=1"`
+}
+
+type UpdateSubMappingRequest struct {
+	Description *string       `json:"description"`
+	Rules       []MappingRule `json:"rules" binding:"required" validate:"required,min=1"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// MappingSetVersion is an immutable snapshot of a client's mapping rules.
+// Every publish or rollback creates a new version number.
+type MappingSetVersion struct {
+	ID             uint            `gorm:"primaryKey" json:"id"`
+	ClientID       uint            `gorm:"not null;uniqueIndex:idx_client_version" json:"client_id"`
+	Client         Client          `gorm:"foreignKey:ClientID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE;" json:"-" validate:"-"`
+	Version        int             `gorm:"not null;uniqueIndex:idx_client_version" json:"version"`
+	Rules          MappingRuleList `gorm:"type:jsonb;not null" json:"rules"`
+	Comment        string          `gorm:"type:text" json:"comment"`
+	RolledBackFrom int             `gorm:"default:0" json:"rolled_back_from,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+type PublishVersionRequest struct {
+	Comment string `json:"comment"`
+}
@@ -0,0 +1,232 @@
+package utils
+
+import (
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// collectionFuncs are the expression helpers for arrays of objects. Fields
+// are dotted paths into each element, so "address.pincode" reaches nested
+// values. Non-object elements simply have no fields.
+func collectionFuncs() map[string]interface{} {
+	return map[string]interface{}{
+		// pluck(arr, "field") lists the field of every element.
+		"pluck": func(arr interface{}, field string) []interface{} {
+			items := toList(arr)
+			out := make([]interface{}, 0, len(items))
+			for _, item := range items {
+				out = append(out, fieldOf(item, field))
+			}
+			return out
+		},
+		// findBy(arr, "field", value) returns the first element whose field
+		// equals value, or nil.
+		"findBy": func(arr interface{}, field string, value interface{}) interface{} {
+			for _, item := range toList(arr) {
+				if looseEqual(fieldOf(item, field), value) {
+					return item
+				}
+			}
+			return nil
+		},
+		// sumBy(arr, "field") adds up the numeric values of the field,
+		// parsing numeric strings and skipping anything else.
+		"sumBy": func(arr interface{}, field string) float64 {
+			total := 0.0
+			for _, item := range toList(arr) {
+				if n, ok := toNumber(fieldOf(item, field)); ok {
+					total += n
+				}
+			}
+			return total
+		},
+		// groupBy(arr, "field") maps each field value to its elements.
+		"groupBy": func(arr interface{}, field string) map[string]interface{} {
+			groups := make(map[string]interface{})
+			for _, item := range toList(arr) {
+				key := FormatScalar(fieldOf(item, field))
+				group, _ := groups[key].([]interface{})
+				groups[key] = append(group, item)
+			}
+			return groups
+		},
+		// sortBy(arr, "field") sorts by the field, numbers numerically and
+		// everything else as text, with missing values last. A leading '-'
+		// on the field sorts in descending order.
+		"sortBy": func(arr interface{}, field string) []interface{} {
+			desc := strings.HasPrefix(field, "-")
+			field = strings.TrimPrefix(field, "-")
+			out := append([]interface{}{}, toList(arr)...)
+			sort.SliceStable(out, func(i, j int) bool {
+				a, b := fieldOf(out[i], field), fieldOf(out[j], field)
+				if a == nil || b == nil {
+					return a != nil && b == nil
+				}
+				if desc {
+					return orderValues(b, a) < 0
+				}
+				return orderValues(a, b) < 0
+			})
+			return out
+		},
+		// uniqueBy(arr, "field") keeps the first element for each field value.
+		"uniqueBy": func(arr interface{}, field string) []interface{} {
+			seen := make(map[string]bool)
+			var out []interface{}
+			for _, item := range toList(arr) {
+				key := FormatScalar(fieldOf(item, field))
+				if !seen[key] {
+					seen[key] = true
+					out = append(out, item)
+				}
+			}
+			return out
+		},
+		"first": func(arr interface{}) interface{} {
+			if items := toList(arr); len(items) > 0 {
+				return items[0]
+			}
+			return nil
+		},
+		"last": func(arr interface{}) interface{} {
+			if items := toList(arr); len(items) > 0 {
+				return items[len(items)-1]
+			}
+			return nil
+		},
+		// flatten(arr) expands nested arrays into a single array.
+		"flatten": func(arr interface{}) []interface{} {
+			var out []interface{}
+			var walk func(v interface{})
+			walk = func(v interface{}) {
+				if isList(v) {
+					for _, item := range toList(v) {
+						walk(item)
+					}
+					return
+				}
+				out = append(out, v)
+			}
+			for _, item := range toList(arr) {
+				walk(item)
+			}
+			return out
+		},
+		// zip(a, b, ...) pairs up elements by position, stopping at the
+		// shortest array.
+		"zip": func(arrs ...interface{}) []interface{} {
+			if len(arrs) == 0 {
+				return []interface{}{}
+			}
+			lists := make([][]interface{}, len(arrs))
+			n := -1
+			for i, arr := range arrs {
+				lists[i] = toList(arr)
+				if n < 0 || len(lists[i]) < n {
+					n = len(lists[i])
+				}
+			}
+			out := make([]interface{}, n)
+			for i := 0; i < n; i++ {
+				tuple := make([]interface{}, len(lists))
+				for j, list := range lists {
+					tuple[j] = list[i]
+				}
+				out[i] = tuple
+			}
+			return out
+		},
+	}
+}
+
+// toList converts any slice to []interface{}; other values give nil.
+func toList(v interface{}) []interface{} {
+	if list, ok := v.([]interface{}); ok {
+		return list
+	}
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil
+	}
+	out := make([]interface{}, rv.Len())
+	for i := range out {
+		out[i] = rv.Index(i).Interface()
+	}
+	return out
+}
+
+func isList(v interface{}) bool {
+	if v == nil {
+		return false
+	}
+	kind := reflect.TypeOf(v).Kind()
+	return kind == reflect.Slice || kind == reflect.Array
+}
+
+func fieldOf(item interface{}, field string) interface{} {
+	m, ok := item.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	value, _ := GetNestedValue(m, strings.Split(field, "."))
+	return value
+}
+
+// toNumber reads numbers of any Go numeric type and numeric strings.
+func toNumber(v interface{}) (float64, bool) {
+	switch val := v.(type) {
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(val), 64)
+		return f, err == nil
+	case nil, bool:
+		return 0, false
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// looseEqual compares numbers by value regardless of their Go type and
+// everything else exactly.
+func looseEqual(a, b interface{}) bool {
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if !aString && !bString {
+		if x, ok := toNumber(a); ok {
+			if y, ok := toNumber(b); ok {
+				return x == y
+			}
+		}
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+// orderValues orders two values numerically when both are numbers and as
+// text otherwise.
+func orderValues(a, b interface{}) int {
+	_, aString := a.(string)
+	_, bString := b.(string)
+	if !aString && !bString {
+		if x, ok := toNumber(a); ok {
+			if y, ok := toNumber(b); ok {
+				switch {
+				case x < y:
+					return -1
+				case x > y:
+					return 1
+				}
+				return 0
+			}
+		}
+	}
+	return strings.Compare(FormatScalar(a), FormatScalar(b))
+}
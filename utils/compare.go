@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+)
+
+// FieldDifference is a single field-level mismatch between an expected and an
+// actual document. Kind is one of "missing", "unexpected", "type_mismatch" or
+// "value_mismatch".
+type FieldDifference struct {
+	Path     string      `json:"path"`
+	Kind     string      `json:"kind"`
+	Expected interface{} `json:"expected,omitempty"`
+	Actual   interface{} `json:"actual,omitempty"`
+}
+
+// CompareDocuments reports every field where actual differs from expected.
+// Both values are normalized through JSON first so numbers compare equal
+// regardless of their Go type.
+func CompareDocuments(expected, actual interface{}) []FieldDifference {
+	diffs := []FieldDifference{}
+	compareValues(normalizeJSON(expected), normalizeJSON(actual), "", &diffs)
+	return diffs
+}
+
+func compareValues(expected, actual interface{}, path string, diffs *[]FieldDifference) {
+	if comparableType(expected) != comparableType(actual) {
+		*diffs = append(*diffs, FieldDifference{
+			Path:     displayPath(path),
+			Kind:     "type_mismatch",
+			Expected: expected,
+			Actual:   actual,
+		})
+		return
+	}
+
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act := actual.(map[string]interface{})
+		for _, key := range sortedKeys(exp) {
+			child := joinDotted(path, key)
+			if actVal, ok := act[key]; ok {
+				compareValues(exp[key], actVal, child, diffs)
+			} else {
+				*diffs = append(*diffs, FieldDifference{Path: child, Kind: "missing", Expected: exp[key]})
+			}
+		}
+		for _, key := range sortedKeys(act) {
+			if _, ok := exp[key]; !ok {
+				*diffs = append(*diffs, FieldDifference{Path: joinDotted(path, key), Kind: "unexpected", Actual: act[key]})
+			}
+		}
+	case []interface{}:
+		act := actual.([]interface{})
+		for i := 0; i < len(exp) || i < len(act); i++ {
+			child := joinDotted(path, strconv.Itoa(i))
+			switch {
+			case i >= len(act):
+				*diffs = append(*diffs, FieldDifference{Path: child, Kind: "missing", Expected: exp[i]})
+			case i >= len(exp):
+				*diffs = append(*diffs, FieldDifference{Path: child, Kind: "unexpected", Actual: act[i]})
+			default:
+				compareValues(exp[i], act[i], child, diffs)
+			}
+		}
+	default:
+		if !reflect.DeepEqual(expected, actual) {
+			*diffs = append(*diffs, FieldDifference{
+				Path:     displayPath(path),
+				Kind:     "value_mismatch",
+				Expected: expected,
+				Actual:   actual,
+			})
+		}
+	}
+}
+
+// comparableType is JSONTypeOf with integers and other numbers treated alike.
+func comparableType(v interface{}) string {
+	if t := JSONTypeOf(v); t != "integer" {
+		return t
+	}
+	return "number"
+}
+
+// JSONTypeOf returns the JSON Schema type name of a decoded JSON value.
+func JSONTypeOf(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if val == float64(int64(val)) {
+			return "integer"
+		}
+		return "number"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// normalizeJSON round-trips a value through encoding/json so it only contains
+// decoded JSON types.
+func normalizeJSON(v interface{}) interface{} {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(data, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+func joinDotted(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	return prefix + "." + key
+}
+
+func displayPath(path string) string {
+	if path == "" {
+		return "$"
+	}
+	return path
+}
@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"strings"
+)
+
+// ConflictRule identifies a rule taking part in a destination conflict by its
+// position in the rule set and, once stored, its ID.
+type ConflictRule struct {
+	Index       int    `json:"index"`
+	ID          uint   `json:"id,omitempty"`
+	Destination string `json:"destination"`
+}
+
+// DestinationConflict describes two rules whose destination paths overlap.
+// Kind is "duplicate_destination" when both write the same path,
+// "prefix_conflict" when the later rule writes below the earlier rule's path
+// and so replaces a scalar value with an object, and "unreachable_rule" when
+// the later rule overwrites the whole subtree the earlier rule wrote into.
+// Allowed is set when the later rule is marked with allow_override, and Set
+// names the mapping set both rules belong to.
+type DestinationConflict struct {
+	Set     string       `json:"set,omitempty"`
+	Kind    string       `json:"kind"`
+	Earlier ConflictRule `json:"earlier"`
+	Later   ConflictRule `json:"later"`
+	Message string       `json:"message"`
+	Allowed bool         `json:"allowed"`
+}
+
+// AnalyzeDestinations reports every pair of rules whose destination paths
+// overlap, taking into account that rules are applied in order and later
+// writes replace earlier ones. Rules of different mapping sets never run
+// together and are not compared.
+func AnalyzeDestinations(rules []models.MappingRule) []DestinationConflict {
+	conflicts := []DestinationConflict{}
+	for j := range rules {
+		for i := 0; i < j; i++ {
+			earlier, later := rules[i], rules[j]
+			if earlier.Set != later.Set {
+				continue
+			}
+			kind, message := classifyOverlap(earlier.DestinationPath, later.DestinationPath)
+			if kind == "" {
+				continue
+			}
+			conflicts = append(conflicts, DestinationConflict{
+				Set:     later.Set,
+				Kind:    kind,
+				Earlier: conflictRule(i, earlier),
+				Later:   conflictRule(j, later),
+				Message: message,
+				Allowed: later.AllowOverride,
+			})
+		}
+	}
+	return conflicts
+}
+
+// BlockingConflicts returns the conflicts that are not allowed and involve at
+// least one rule accepted by touched. A nil touched function matches all rules.
+func BlockingConflicts(conflicts []DestinationConflict, touched func(ConflictRule) bool) []DestinationConflict {
+	blocking := []DestinationConflict{}
+	for _, conflict := range conflicts {
+		if conflict.Allowed {
+			continue
+		}
+		if touched == nil || touched(conflict.Earlier) || touched(conflict.Later) {
+			blocking = append(blocking, conflict)
+		}
+	}
+	return blocking
+}
+
+func classifyOverlap(earlier, later []string) (string, string) {
+	e, l := strings.Join(earlier, "."), strings.Join(later, ".")
+	switch {
+	case e == l:
+		return "duplicate_destination", fmt.Sprintf("both rules write %s; the later rule overwrites the earlier one", e)
+	case isPathPrefix(earlier, later):
+		return "prefix_conflict", fmt.Sprintf("the later rule writes %s inside %s, replacing the earlier rule's value unless it is an object", l, e)
+	case isPathPrefix(later, earlier):
+		return "unreachable_rule", fmt.Sprintf("the later rule overwrites %s, discarding %s written by the earlier rule", l, e)
+	}
+	return "", ""
+}
+
+// isPathPrefix reports whether prefix is a strict prefix of path.
+func isPathPrefix(prefix, path []string) bool {
+	if len(prefix) >= len(path) {
+		return false
+	}
+	for i := range prefix {
+		if prefix[i] != path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func conflictRule(index int, rule models.MappingRule) ConflictRule {
+	return ConflictRule{Index: index, ID: rule.ID, Destination: strings.Join(rule.DestinationPath, ".")}
+}
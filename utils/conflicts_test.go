@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// conflictKinds lists each conflict as "kind earlier>later".
+func conflictKinds(conflicts []DestinationConflict) []string {
+	var kinds []string
+	for _, c := range conflicts {
+		kinds = append(kinds, fmt.Sprintf("%s %d>%d", c.Kind, c.Earlier.Index, c.Later.Index))
+	}
+	return kinds
+}
+
+func TestAnalyzeDestinationsClassifiesByRuleOrder(t *testing.T) {
+	// The same pair of paths is a prefix conflict or an unreachable rule
+	// depending on which rule runs last.
+	parentFirst := []models.MappingRule{copyRule("a", "loan"), copyRule("b", "loan.id")}
+	if got := conflictKinds(AnalyzeDestinations(parentFirst)); !reflect.DeepEqual(got, []string{"prefix_conflict 0>1"}) {
+		t.Errorf("parent first: %v", got)
+	}
+	childFirst := []models.MappingRule{copyRule("b", "loan.id"), copyRule("a", "loan")}
+	if got := conflictKinds(AnalyzeDestinations(childFirst)); !reflect.DeepEqual(got, []string{"unreachable_rule 0>1"}) {
+		t.Errorf("child first: %v", got)
+	}
+}
+
+func TestAnalyzeDestinationsReportsEveryPair(t *testing.T) {
+	rules := []models.MappingRule{
+		copyRule("x", "a.b"),
+		copyRule("x", "c"),
+		copyRule("x", "a"),
+		copyRule("x", "a.b"),
+		copyRule("x", "a.bc"), // shares characters, not a path segment
+	}
+	rules[3].AllowOverride = true
+
+	conflicts := AnalyzeDestinations(rules)
+	want := []string{
+		"unreachable_rule 0>2",
+		"duplicate_destination 0>3",
+		"prefix_conflict 2>3",
+		"prefix_conflict 2>4",
+	}
+	if got := conflictKinds(conflicts); !reflect.DeepEqual(got, want) {
+		t.Fatalf("conflicts = %v, want %v", got, want)
+	}
+	for _, c := range conflicts {
+		if c.Allowed != (c.Later.Index == 3) {
+			t.Errorf("%s %d>%d: allowed = %v, want it to follow the later rule's allow_override", c.Kind, c.Earlier.Index, c.Later.Index, c.Allowed)
+		}
+	}
+	if conflicts[1].Earlier.Destination != "a.b" || conflicts[1].Message == "" {
+		t.Errorf("duplicate conflict = %+v, want the destination and a message", conflicts[1])
+	}
+}
+
+func TestAnalyzeDestinationsWithoutOverlap(t *testing.T) {
+	rules := []models.MappingRule{copyRule("a", "loan.id"), copyRule("b", "loan.amount"), copyRule("c", "loanId")}
+	if conflicts := AnalyzeDestinations(rules); conflicts == nil || len(conflicts) != 0 {
+		t.Errorf("conflicts = %#v, want an empty list", conflicts)
+	}
+}
+
+func TestBlockingConflicts(t *testing.T) {
+	conflicts := []DestinationConflict{
+		{Kind: "duplicate_destination", Earlier: ConflictRule{Index: 0}, Later: ConflictRule{Index: 1}},
+		{Kind: "prefix_conflict", Earlier: ConflictRule{Index: 1}, Later: ConflictRule{Index: 2}, Allowed: true},
+		{Kind: "unreachable_rule", Earlier: ConflictRule{Index: 3}, Later: ConflictRule{Index: 4}},
+	}
+
+	if got := conflictKinds(BlockingConflicts(conflicts, nil)); !reflect.DeepEqual(got, []string{"duplicate_destination 0>1", "unreachable_rule 3>4"}) {
+		t.Errorf("all rules: %v", got)
+	}
+	touched := func(index int) func(ConflictRule) bool {
+		return func(r ConflictRule) bool { return r.Index == index }
+	}
+	if got := conflictKinds(BlockingConflicts(conflicts, touched(4))); !reflect.DeepEqual(got, []string{"unreachable_rule 3>4"}) {
+		t.Errorf("rule 4 touched: %v", got)
+	}
+	if got := BlockingConflicts(conflicts, touched(2)); len(got) != 0 {
+		t.Errorf("allowed conflicts must not block: %v", got)
+	}
+}
+
+func TestAnalyzeDestinationsComparesWithinSets(t *testing.T) {
+	rules := []models.MappingRule{copyRule("a", "loan.id"), copyRule("b", "loan.id"), copyRule("c", "loan.id")}
+	rules[0].Set = "secured"
+	rules[2].Set = "secured"
+
+	conflicts := AnalyzeDestinations(rules)
+	if got := conflictKinds(conflicts); !reflect.DeepEqual(got, []string{"duplicate_destination 0>2"}) {
+		t.Fatalf("conflicts = %v, want only the pair in the secured set", got)
+	}
+	if conflicts[0].Set != "secured" {
+		t.Errorf("set = %q, want secured", conflicts[0].Set)
+	}
+}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// ConstraintViolation is a value in the output that breaks a rule's
+// constraints. Path is the concrete dotted path, with array indexes filled in
+// when the rule's destination fans out across array elements.
+type ConstraintViolation struct {
+	Path       string      `json:"path"`
+	RuleIndex  int         `json:"rule_index"`
+	RuleID     uint        `json:"rule_id,omitempty"`
+	Constraint string      `json:"constraint"`
+	Message    string      `json:"message"`
+	Value      interface{} `json:"value,omitempty"`
+}
+
+var constraintTypes = map[string]bool{
+	"string": true, "number": true, "integer": true, "boolean": true,
+	"object": true, "array": true, "null": true,
+}
+
+// ValidateConstraints checks that a rule's constraints are well formed.
+func ValidateConstraints(c models.Constraints) error {
+	if c.Type != "" && !constraintTypes[c.Type] {
+		return fmt.Errorf("constraints.type %q is not a JSON type", c.Type)
+	}
+	if c.Pattern != "" {
+		if _, err := regexp.Compile(c.Pattern); err != nil {
+			return fmt.Errorf("constraints.pattern is invalid: %s", err.Error())
+		}
+	}
+	if c.Min != nil && c.Max != nil && *c.Min > *c.Max {
+		return fmt.Errorf("constraints.min is greater than constraints.max")
+	}
+	if (c.MinLength != nil && *c.MinLength < 0) || (c.MaxLength != nil && *c.MaxLength < 0) {
+		return fmt.Errorf("constraints lengths must not be negative")
+	}
+	if c.MinLength != nil && c.MaxLength != nil && *c.MinLength > *c.MaxLength {
+		return fmt.Errorf("constraints.min_length is greater than constraints.max_length")
+	}
+	return nil
+}
+
+// ValidateOutput checks every rule's destination in output against the rule's
+// required flag and constraints. A destination key that meets an array is
+// applied to each element, so a rule writing applicants.mobile is checked in
+// every applicant, not just the first.
+func ValidateOutput(output map[string]interface{}, rules []models.MappingRule) []ConstraintViolation {
+	violations := []ConstraintViolation{}
+	normalized, _ := normalizeJSON(output).(map[string]interface{})
+	for i, rule := range rules {
+		if !rule.Required && rule.Constraints == nil {
+			continue
+		}
+		var pattern *regexp.Regexp
+		if rule.Constraints != nil && rule.Constraints.Pattern != "" {
+			pattern, _ = regexp.Compile(rule.Constraints.Pattern)
+		}
+		resolveFanOut(normalized, rule.DestinationPath, nil, func(path []string, value interface{}, exists bool) {
+			add := func(constraint, message string) {
+				v := ConstraintViolation{
+					Path:       strings.Join(path, "."),
+					RuleIndex:  i,
+					RuleID:     rule.ID,
+					Constraint: constraint,
+					Message:    message,
+				}
+				if exists {
+					v.Value = value
+				}
+				violations = append(violations, v)
+			}
+			if !exists {
+				if rule.Required {
+					add("required", "required field is missing")
+				}
+				return
+			}
+			if rule.Constraints != nil {
+				checkConstraints(*rule.Constraints, pattern, value, add)
+			}
+		})
+	}
+	return violations
+}
+
+func checkConstraints(c models.Constraints, pattern *regexp.Regexp, value interface{}, add func(constraint, message string)) {
+	if c.Type != "" {
+		actual := JSONTypeOf(value)
+		if actual != c.Type && !(c.Type == "number" && actual == "integer") {
+			add("type", fmt.Sprintf("expected %s, got %s", c.Type, actual))
+			return
+		}
+	}
+	if value == nil {
+		return
+	}
+
+	if len(c.Enum) > 0 {
+		allowed := false
+		for _, option := range c.Enum {
+			if reflect.DeepEqual(normalizeJSON(option), value) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			add("enum", "value is not one of the allowed values")
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		if pattern != nil && !pattern.MatchString(v) {
+			add("pattern", fmt.Sprintf("value does not match %s", c.Pattern))
+		}
+		checkLength(c, utf8.RuneCountInString(v), add)
+	case []interface{}:
+		checkLength(c, len(v), add)
+	case float64:
+		if c.Min != nil && v < *c.Min {
+			add("min", fmt.Sprintf("value is less than %s", strconv.FormatFloat(*c.Min, 'f', -1, 64)))
+		}
+		if c.Max != nil && v > *c.Max {
+			add("max", fmt.Sprintf("value is greater than %s", strconv.FormatFloat(*c.Max, 'f', -1, 64)))
+		}
+	}
+}
+
+func checkLength(c models.Constraints, length int, add func(constraint, message string)) {
+	if c.MinLength != nil && length < *c.MinLength {
+		add("min_length", fmt.Sprintf("length %d is less than %d", length, *c.MinLength))
+	}
+	if c.MaxLength != nil && length > *c.MaxLength {
+		add("max_length", fmt.Sprintf("length %d is greater than %d", length, *c.MaxLength))
+	}
+}
+
+// resolveFanOut walks path through data and calls visit for every value it
+// reaches. Numeric keys index arrays; any other key meeting an array is
+// applied to each element. A missing value is visited once with the full
+// path it was expected at.
+func resolveFanOut(data interface{}, path []string, prefix []string, visit func(path []string, value interface{}, exists bool)) {
+	if len(path) == 0 {
+		visit(prefix, data, true)
+		return
+	}
+	key := path[0]
+	switch v := data.(type) {
+	case map[string]interface{}:
+		if next, ok := v[key]; ok {
+			resolveFanOut(next, path[1:], appendPath(prefix, key), visit)
+			return
+		}
+	case []interface{}:
+		if idx, err := strconv.Atoi(key); err == nil {
+			if idx >= 0 && idx < len(v) {
+				resolveFanOut(v[idx], path[1:], appendPath(prefix, key), visit)
+				return
+			}
+		} else {
+			for i, elem := range v {
+				resolveFanOut(elem, path, appendPath(prefix, strconv.Itoa(i)), visit)
+			}
+			return
+		}
+	}
+	visit(append(append([]string{}, prefix...), path...), nil, false)
+}
+
+// MissingRequiredPaths lists the distinct paths of "required" violations.
+func MissingRequiredPaths(violations []ConstraintViolation) []string {
+	seen := make(map[string]bool)
+	var paths []string
+	for _, v := range violations {
+		if v.Constraint == "required" && !seen[v.Path] {
+			seen[v.Path] = true
+			paths = append(paths, v.Path)
+		}
+	}
+	return paths
+}
@@ -0,0 +1,261 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antonmedv/expr/ast"
+	"github.com/antonmedv/expr/parser"
+)
+
+// CoverageReport describes how much of an input document a rule set reads.
+// UnmappedPaths lists input leaves that no rule reads, either through its
+// source path or through input/getPath references in its expression.
+// UnresolvedRules lists the rules whose source path found no value.
+type CoverageReport struct {
+	InputLeaves     int              `json:"input_leaves"`
+	ConsumedLeaves  int              `json:"consumed_leaves"`
+	UnmappedPaths   []string         `json:"unmapped_paths"`
+	UnresolvedRules []UnresolvedRule `json:"unresolved_rules"`
+}
+
+// UnresolvedRule identifies a rule whose source value was missing.
+type UnresolvedRule struct {
+	Index           int    `json:"index"`
+	ID              uint   `json:"id,omitempty"`
+	SourcePath      string `json:"source_path"`
+	DestinationPath string `json:"destination_path"`
+}
+
+// AnalyzeCoverage reports which input leaves the rules never read and which
+// rules found no source value in input.
+func AnalyzeCoverage(input map[string]interface{}, rules []models.MappingRule) CoverageReport {
+	report := CoverageReport{
+		UnmappedPaths:   []string{},
+		UnresolvedRules: []UnresolvedRule{},
+	}
+
+	consumed := inputReads(input, rules)
+	for i, rule := range rules {
+		if _, exists := ResolveSourcePath(input, rule.SourcePath); !exists {
+			report.UnresolvedRules = append(report.UnresolvedRules, UnresolvedRule{
+				Index:           i,
+				ID:              rule.ID,
+				SourcePath:      strings.Join(rule.SourcePath, "."),
+				DestinationPath: strings.Join(rule.DestinationPath, "."),
+			})
+		}
+	}
+
+	for _, leaf := range LeafPaths(input) {
+		report.InputLeaves++
+		if pathConsumed(leaf, consumed) {
+			report.ConsumedLeaves++
+		} else {
+			report.UnmappedPaths = append(report.UnmappedPaths, strings.Join(leaf, "."))
+		}
+	}
+	sort.Strings(report.UnmappedPaths)
+	return report
+}
+
+// ConsumedInputPaths returns the input paths a rule reads: its source path and
+// every input member chain or getPath(input, ...) call in its expression.
+// Reading a path reads everything below it; an empty path means the rule
+// reads the whole input. A JSONPath source contributes the keys before its
+// first data-dependent selector.
+func ConsumedInputPaths(rule models.MappingRule) [][]string {
+	source := []string(rule.SourcePath)
+	if models.IsJSONPath(rule.SourcePath) {
+		source = nil
+		if p, err := cachedJSONPath(rule.SourcePath[0]); err == nil {
+			source = p.StaticPrefix()
+		}
+	}
+	return append([][]string{source}, expressionInputPaths(rule)...)
+}
+
+// inputReads returns the concrete paths rules read in input, resolving
+// JSONPath sources to the nodes they match.
+func inputReads(input map[string]interface{}, rules []models.MappingRule) [][]string {
+	var reads [][]string
+	for _, rule := range rules {
+		reads = append(reads, sourcePathReads(input, rule.SourcePath)...)
+		reads = append(reads, expressionInputPaths(rule)...)
+	}
+	return reads
+}
+
+func expressionInputPaths(rule models.MappingRule) [][]string {
+	var paths [][]string
+	if rule.TransformLogic == "" || rule.TransformType == "ungroup" || rule.TransformType == "subMapping" {
+		return paths
+	}
+	tree, err := parser.Parse(rule.TransformLogic)
+	if err != nil {
+		return paths
+	}
+	collectInputReferences(tree.Node, &paths)
+	return paths
+}
+
+// collectInputReferences walks an expression and records the input paths it
+// references. Member chains are resolved as a whole so that
+// input.applicantDetails[0].mobileNo does not also count as reading all of
+// input.applicantDetails.
+func collectInputReferences(node ast.Node, paths *[][]string) {
+	switch n := node.(type) {
+	case nil:
+	case *ast.IdentifierNode:
+		if n.Value == "input" {
+			*paths = append(*paths, []string{})
+		}
+	case *ast.MemberNode, *ast.ChainNode:
+		root, path, dynamic := memberChain(n)
+		if ident, ok := root.(*ast.IdentifierNode); ok && ident.Value == "input" {
+			*paths = append(*paths, path)
+		} else {
+			collectInputReferences(root, paths)
+		}
+		for _, property := range dynamic {
+			collectInputReferences(property, paths)
+		}
+	case *ast.CallNode:
+		if callee, ok := n.Callee.(*ast.IdentifierNode); ok && callee.Value == "getPath" && len(n.Arguments) > 0 {
+			if ident, ok := n.Arguments[0].(*ast.IdentifierNode); ok && ident.Value == "input" {
+				var path []string
+				rest := n.Arguments[1:]
+				for len(rest) > 0 {
+					str, ok := rest[0].(*ast.StringNode)
+					if !ok {
+						break
+					}
+					path = append(path, str.Value)
+					rest = rest[1:]
+				}
+				*paths = append(*paths, path)
+				for _, arg := range rest {
+					collectInputReferences(arg, paths)
+				}
+				return
+			}
+		}
+		collectInputReferences(n.Callee, paths)
+		for _, arg := range n.Arguments {
+			collectInputReferences(arg, paths)
+		}
+	case *ast.UnaryNode:
+		collectInputReferences(n.Node, paths)
+	case *ast.BinaryNode:
+		collectInputReferences(n.Left, paths)
+		collectInputReferences(n.Right, paths)
+	case *ast.SliceNode:
+		collectInputReferences(n.Node, paths)
+		collectInputReferences(n.From, paths)
+		collectInputReferences(n.To, paths)
+	case *ast.BuiltinNode:
+		for _, arg := range n.Arguments {
+			collectInputReferences(arg, paths)
+		}
+	case *ast.ClosureNode:
+		collectInputReferences(n.Node, paths)
+	case *ast.ConditionalNode:
+		collectInputReferences(n.Cond, paths)
+		collectInputReferences(n.Exp1, paths)
+		collectInputReferences(n.Exp2, paths)
+	case *ast.ArrayNode:
+		for _, elem := range n.Nodes {
+			collectInputReferences(elem, paths)
+		}
+	case *ast.MapNode:
+		for _, pair := range n.Pairs {
+			collectInputReferences(pair, paths)
+		}
+	case *ast.PairNode:
+		collectInputReferences(n.Key, paths)
+		collectInputReferences(n.Value, paths)
+	}
+}
+
+// memberChain unwinds a chain of member accesses into its root node and the
+// constant keys leading to it. The chain is cut at the first computed
+// property, which is returned in dynamic along with any later ones.
+func memberChain(node ast.Node) (ast.Node, []string, []ast.Node) {
+	var keys []string
+	var dynamic []ast.Node
+	for {
+		switch n := node.(type) {
+		case *ast.ChainNode:
+			node = n.Node
+			continue
+		case *ast.MemberNode:
+			switch property := n.Property.(type) {
+			case *ast.StringNode:
+				keys = append([]string{property.Value}, keys...)
+			case *ast.IntegerNode:
+				keys = append([]string{strconv.Itoa(property.Value)}, keys...)
+			default:
+				keys = nil
+				dynamic = append(dynamic, n.Property)
+			}
+			node = n.Node
+			continue
+		}
+		return node, keys, dynamic
+	}
+}
+
+// LeafPaths lists the paths of every scalar, empty object and empty array in
+// data.
+func LeafPaths(data interface{}) [][]string {
+	var leaves [][]string
+	var walk func(value interface{}, path []string)
+	walk = func(value interface{}, path []string) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			if len(v) == 0 && len(path) > 0 {
+				leaves = append(leaves, path)
+			}
+			for key, elem := range v {
+				walk(elem, appendPath(path, key))
+			}
+		case []interface{}:
+			if len(v) == 0 && len(path) > 0 {
+				leaves = append(leaves, path)
+			}
+			for i, elem := range v {
+				walk(elem, appendPath(path, strconv.Itoa(i)))
+			}
+		default:
+			leaves = append(leaves, path)
+		}
+	}
+	walk(data, nil)
+	return leaves
+}
+
+func appendPath(path []string, key string) []string {
+	next := make([]string, len(path)+1)
+	copy(next, path)
+	next[len(path)] = key
+	return next
+}
+
+// pathConsumed reports whether leaf lies at or below any consumed path.
+func pathConsumed(leaf []string, consumed [][]string) bool {
+next:
+	for _, prefix := range consumed {
+		if len(prefix) > len(leaf) {
+			continue
+		}
+		for i := range prefix {
+			if prefix[i] != leaf[i] {
+				continue next
+			}
+		}
+		return true
+	}
+	return false
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyzeCoverage(t *testing.T) {
+	input := mustJSON(t, `{
+		"loan": {"id": 7, "amount": 100, "term": 12},
+		"applicant": {"name": "Asha", "phones": ["1", "2"]},
+		"extra": true
+	}`).(map[string]interface{})
+	rules := []models.MappingRule{
+		{ID: 1, SourcePath: models.JSONStringList{"loan", "id"}, DestinationPath: models.JSONStringList{"ref"}, TransformType: "copy"},
+		{ID: 2, SourcePath: models.JSONStringList{"applicant", "phones"}, DestinationPath: models.JSONStringList{"phones"}, TransformType: "copy"},
+		{ID: 3, SourcePath: models.JSONStringList{"loan", "rate"}, DestinationPath: models.JSONStringList{"rate"}, TransformType: "expression",
+			TransformLogic: `input.loan.amount * 2 + getPath(input, "applicant", "name")`},
+	}
+
+	report := AnalyzeCoverage(input, rules)
+
+	// Reading a path covers everything below it, and expressions count
+	// through member chains and getPath
+	if report.InputLeaves != 7 || report.ConsumedLeaves != 5 {
+		t.Errorf("leaves = %d consumed of %d, want 5 of 7", report.ConsumedLeaves, report.InputLeaves)
+	}
+	if want := []string{"extra", "loan.term"}; !reflect.DeepEqual(report.UnmappedPaths, want) {
+		t.Errorf("unmapped = %v, want %v", report.UnmappedPaths, want)
+	}
+	want := []UnresolvedRule{{Index: 2, ID: 3, SourcePath: "loan.rate", DestinationPath: "rate"}}
+	if !reflect.DeepEqual(report.UnresolvedRules, want) {
+		t.Errorf("unresolved = %+v, want %+v", report.UnresolvedRules, want)
+	}
+}
+
+// A subMapping rule reads its source path as a whole; the name in its
+// transform logic is not an expression.
+func TestAnalyzeCoverageSubMapping(t *testing.T) {
+	input := mustJSON(t, `{"home": {"city": "Pune", "zip": "411001"}, "id": 1}`).(map[string]interface{})
+	rules := []models.MappingRule{
+		{SourcePath: models.JSONStringList{"home"}, DestinationPath: models.JSONStringList{"address"}, TransformType: "subMapping", TransformLogic: "input.id",
+			SubRules: models.MappingRuleList{{SourcePath: models.JSONStringList{"city"}, DestinationPath: models.JSONStringList{"town"}, TransformType: "copy"}}},
+	}
+
+	report := AnalyzeCoverage(input, rules)
+	if want := []string{"id"}; !reflect.DeepEqual(report.UnmappedPaths, want) || len(report.UnresolvedRules) != 0 {
+		t.Errorf("report = %+v, want only id unmapped", report)
+	}
+}
@@ -0,0 +1,222 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// maxExpandedArrayIndex caps the array index a dotted header may address so a
+// header like "items.999999999" cannot force a huge allocation.
+const maxExpandedArrayIndex = 10000
+
+// CSVOptions controls how tabular input is parsed into records.
+type CSVOptions struct {
+	Delimiter  rune   // Field separator, defaults to ','
+	Quoting    string // "strict" (default), "lazy" or "none"
+	Encoding   string // "utf-8" (default), "latin1", "utf-16le" or "utf-16be"
+	InferTypes bool   // Convert numeric, boolean and null cells to typed values
+}
+
+// ParseCSVRecords reads a header row followed by data rows and returns one
+// record per row. Dotted headers such as "applicantDetails.0.mobileNo" are
+// expanded into nested maps and arrays. Empty cells are left out of the record.
+func ParseCSVRecords(r io.Reader, opts CSVOptions) ([]map[string]interface{}, error) {
+	decoded, err := DecodeText(r, opts.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Delimiter == 0 {
+		opts.Delimiter = ','
+	}
+
+	rows, err := readCSVRows(decoded, opts)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("CSV input has no header row")
+	}
+
+	headers := make([][]string, len(rows[0]))
+	for i, h := range rows[0] {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			return nil, fmt.Errorf("CSV header column %d is empty", i+1)
+		}
+		headers[i] = strings.Split(h, ".")
+	}
+
+	records := make([]map[string]interface{}, 0, len(rows)-1)
+	for n, row := range rows[1:] {
+		if len(row) > len(headers) {
+			return nil, fmt.Errorf("CSV row %d has %d fields, header has %d", n+2, len(row), len(headers))
+		}
+		record := make(map[string]interface{})
+		for i, cell := range row {
+			if cell == "" {
+				continue
+			}
+			var value interface{} = cell
+			if opts.InferTypes {
+				value = InferScalar(cell)
+			}
+			SetPathValue(record, headers[i], value)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func readCSVRows(r io.Reader, opts CSVOptions) ([][]string, error) {
+	switch opts.Quoting {
+	case "", "strict", "lazy":
+		reader := csv.NewReader(r)
+		reader.Comma = opts.Delimiter
+		reader.LazyQuotes = opts.Quoting == "lazy"
+		reader.FieldsPerRecord = -1
+		return reader.ReadAll()
+	case "none":
+		var rows [][]string
+		scanner := bufio.NewScanner(r)
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		for scanner.Scan() {
+			line := strings.TrimSuffix(scanner.Text(), "\r")
+			if line == "" {
+				continue
+			}
+			rows = append(rows, strings.Split(line, string(opts.Delimiter)))
+		}
+		return rows, scanner.Err()
+	default:
+		return nil, fmt.Errorf("unsupported quoting mode %q", opts.Quoting)
+	}
+}
+
+// DecodeText converts input in the named encoding to UTF-8 and strips any
+// leading byte order mark.
+func DecodeText(r io.Reader, encoding string) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(strings.ReplaceAll(encoding, "_", "-")) {
+	case "", "utf-8", "utf8":
+		return bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))), nil
+	case "latin1", "latin-1", "iso-8859-1":
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return strings.NewReader(string(runes)), nil
+	case "utf-16le", "utf-16be", "utf-16":
+		if len(data)%2 != 0 {
+			return nil, fmt.Errorf("invalid UTF-16 input: odd number of bytes")
+		}
+		bigEndian := strings.HasSuffix(strings.ToLower(encoding), "be")
+		if len(data) >= 2 {
+			switch {
+			case data[0] == 0xFF && data[1] == 0xFE:
+				bigEndian, data = false, data[2:]
+			case data[0] == 0xFE && data[1] == 0xFF:
+				bigEndian, data = true, data[2:]
+			}
+		}
+		units := make([]uint16, len(data)/2)
+		for i := range units {
+			if bigEndian {
+				units[i] = uint16(data[2*i])<<8 | uint16(data[2*i+1])
+			} else {
+				units[i] = uint16(data[2*i+1])<<8 | uint16(data[2*i])
+			}
+		}
+		return strings.NewReader(string(utf16.Decode(units))), nil
+	default:
+		return nil, fmt.Errorf("unsupported encoding %q", encoding)
+	}
+}
+
+// InferScalar converts a text cell to a boolean, number or nil when it
+// unambiguously looks like one. Values with leading zeros such as account
+// numbers, and numbers with more significant digits than a float64 holds
+// exactly, such as card or reference numbers, stay strings.
+func InferScalar(s string) interface{} {
+	switch strings.ToLower(s) {
+	case "true":
+		return true
+	case "false":
+		return false
+	case "null":
+		return nil
+	}
+
+	digits := strings.TrimPrefix(s, "-")
+	if len(digits) > 1 && digits[0] == '0' && digits[1] != '.' {
+		return s
+	}
+	if significantDigits(digits) > maxExactDigits {
+		return s
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return float64(i)
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil && !strings.ContainsAny(s, "xXnN") {
+		return f
+	}
+	return s
+}
+
+// maxExactDigits is the number of significant decimal digits that always
+// survive a round trip through a float64.
+const maxExactDigits = 15
+
+// significantDigits counts the digits of a number's mantissa, ignoring
+// leading zeros, the decimal point and any exponent.
+func significantDigits(number string) int {
+	if i := strings.IndexAny(number, "eE"); i >= 0 {
+		number = number[:i]
+	}
+	number = strings.TrimLeft(strings.Replace(number, ".", "", 1), "0")
+	return len(number)
+}
+
+// SetPathValue sets a value in a nested structure like SetNestedValue, but
+// creates arrays for numeric path segments so "applicantDetails.0.mobileNo"
+// produces {"applicantDetails": [{"mobileNo": ...}]}.
+func SetPathValue(data map[string]interface{}, path []string, value interface{}) {
+	if len(path) == 0 {
+		return
+	}
+	data[path[0]] = setPathValue(data[path[0]], path[1:], value)
+}
+
+func setPathValue(current interface{}, path []string, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	key := path[0]
+
+	if m, ok := current.(map[string]interface{}); ok {
+		m[key] = setPathValue(m[key], path[1:], value)
+		return m
+	}
+
+	if idx, err := strconv.Atoi(key); err == nil && idx >= 0 && idx < maxExpandedArrayIndex {
+		arr, _ := current.([]interface{})
+		for len(arr) <= idx {
+			arr = append(arr, nil)
+		}
+		arr[idx] = setPathValue(arr[idx], path[1:], value)
+		return arr
+	}
+
+	m := make(map[string]interface{})
+	m[key] = setPathValue(nil, path[1:], value)
+	return m
+}
@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+func mustJSON(t *testing.T, s string) interface{} {
+	t.Helper()
+	var v interface{}
+	if err := json.Unmarshal([]byte(s), &v); err != nil {
+		t.Fatalf("invalid JSON %s: %v", s, err)
+	}
+	return v
+}
+
+func utf16Bytes(s string, bigEndian, bom bool) string {
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	b := make([]byte, 0, 2*len(units))
+	for _, u := range units {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return string(b)
+}
+
+func TestParseCSVRecords(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  CSVOptions
+		want  string
+	}{
+		{
+			name:  "flat rows keep text",
+			input: "loanId,amount\nL1,100\nL2,007\n",
+			want:  `[{"loanId": "L1", "amount": "100"}, {"loanId": "L2", "amount": "007"}]`,
+		},
+		{
+			name:  "dotted headers build nested values",
+			input: "loan.id,applicantDetails.0.mobileNo,applicantDetails.1.mobileNo\nL1,98,99\n",
+			want:  `[{"loan": {"id": "L1"}, "applicantDetails": [{"mobileNo": "98"}, {"mobileNo": "99"}]}]`,
+		},
+		{
+			name:  "empty and missing cells are left out",
+			input: "a,b,c\n1,,3\n4\n",
+			want:  `[{"a": "1", "c": "3"}, {"a": "4"}]`,
+		},
+		{
+			name:  "headers are trimmed but cells are not",
+			input: " name , city\n Asha ,Pune\n",
+			want:  `[{"name": " Asha ", "city": "Pune"}]`,
+		},
+		{
+			name:  "header only",
+			input: "a,b\n",
+			want:  `[]`,
+		},
+		{
+			name:  "type inference",
+			input: "int,float,bool,null,zero,account,nan,exp\n-42,0.5,TRUE,null,0,0042,NaN,1e3\n",
+			opts:  CSVOptions{InferTypes: true},
+			want:  `[{"int": -42, "float": 0.5, "bool": true, "null": null, "zero": 0, "account": "0042", "nan": "NaN", "exp": 1000}]`,
+		},
+		{
+			name:  "quoted fields",
+			input: "a,b\n\"x,y\",\"say \"\"hi\"\"\"\n",
+			want:  `[{"a": "x,y", "b": "say \"hi\""}]`,
+		},
+		{
+			name:  "lazy quoting",
+			input: "a,b\nsay \"hi\",2\n",
+			opts:  CSVOptions{Quoting: "lazy"},
+			want:  `[{"a": "say \"hi\"", "b": "2"}]`,
+		},
+		{
+			name:  "no quoting",
+			input: "a;b\r\n\"x\";y\r\n\r\n",
+			opts:  CSVOptions{Quoting: "none", Delimiter: ';'},
+			want:  `[{"a": "\"x\"", "b": "y"}]`,
+		},
+		{
+			name:  "tab delimiter",
+			input: "a\tb\n1\t2\n",
+			opts:  CSVOptions{Delimiter: '\t'},
+			want:  `[{"a": "1", "b": "2"}]`,
+		},
+		{
+			name:  "utf-8 byte order mark",
+			input: "\xef\xbb\xbfname\nAsha\n",
+			want:  `[{"name": "Asha"}]`,
+		},
+		{
+			name:  "latin1",
+			input: "city\nS\xe3o Paulo\n",
+			opts:  CSVOptions{Encoding: "ISO_8859-1"},
+			want:  `[{"city": "São Paulo"}]`,
+		},
+		{
+			name:  "utf-16le with byte order mark",
+			input: utf16Bytes("city\nSão Paulo\n", false, true),
+			opts:  CSVOptions{Encoding: "utf-16"},
+			want:  `[{"city": "São Paulo"}]`,
+		},
+		{
+			name:  "utf-16be without byte order mark",
+			input: utf16Bytes("city\nSão Paulo\n", true, false),
+			opts:  CSVOptions{Encoding: "UTF-16BE"},
+			want:  `[{"city": "São Paulo"}]`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			records, err := ParseCSVRecords(strings.NewReader(tt.input), tt.opts)
+			if err != nil {
+				t.Fatalf("ParseCSVRecords: %v", err)
+			}
+			got := make([]interface{}, len(records))
+			for i, record := range records {
+				got[i] = record
+			}
+			if want := mustJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("records = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseCSVRecordsErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		opts  CSVOptions
+	}{
+		{"empty input", "", CSVOptions{}},
+		{"empty header column", "a,,c\n1,2,3\n", CSVOptions{}},
+		{"row longer than header", "a,b\n1,2,3\n", CSVOptions{}},
+		{"bare quote in strict mode", "a\nsay \"hi\"\n", CSVOptions{}},
+		{"unknown quoting", "a\n1\n", CSVOptions{Quoting: "smart"}},
+		{"unknown encoding", "a\n1\n", CSVOptions{Encoding: "ebcdic"}},
+		{"odd utf-16 length", "a\n1", CSVOptions{Encoding: "utf-16le"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseCSVRecords(strings.NewReader(tt.input), tt.opts); err == nil {
+				t.Error("ParseCSVRecords succeeded, want an error")
+			}
+		})
+	}
+}
+
+func TestInferScalar(t *testing.T) {
+	for cell, want := range map[string]interface{}{
+		"42":    42.0,
+		"-7":    -7.0,
+		"0":     0.0,
+		"0.25":  0.25,
+		"-0.5":  -0.5,
+		"1e3":   1000.0,
+		"True":  true,
+		"false": false,
+		"NULL":  nil,
+		"007":   "007",
+		"-012":  "-012",
+		"NaN":   "NaN",
+		"Inf":   "Inf",
+		"0x1F":  "0x1F",
+		"12abc": "12abc",
+		"":      "",
+
+		"123456789012345":      123456789012345.0,
+		"0.000123456789012345": 0.000123456789012345,
+		"1234567890123456":     "1234567890123456",
+		"-4111111111111111":    "-4111111111111111",
+		"3.14159265358979323":  "3.14159265358979323",
+		"1.0000000000000001e5": "1.0000000000000001e5",
+	} {
+		if got := InferScalar(cell); got != want {
+			t.Errorf("InferScalar(%q) = %#v, want %#v", cell, got, want)
+		}
+	}
+}
+
+func TestSetPathValueMergesArraysAndObjects(t *testing.T) {
+	record := map[string]interface{}{}
+	SetPathValue(record, []string{"applicants", "1", "name"}, "Ravi")
+	SetPathValue(record, []string{"applicants", "0", "name"}, "Asha")
+	SetPathValue(record, []string{"applicants", "0", "phones", "0"}, "98")
+	SetPathValue(record, []string{"loan", "id"}, "L1")
+
+	want := mustJSON(t, `{
+		"applicants": [{"name": "Asha", "phones": ["98"]}, {"name": "Ravi"}],
+		"loan": {"id": "L1"}
+	}`)
+	if !reflect.DeepEqual(record, want) {
+		t.Errorf("record = %v, want %v", record, want)
+	}
+
+	// An index beyond the cap becomes an object key instead of allocating
+	SetPathValue(record, []string{"huge", "999999999"}, "x")
+	if _, ok := record["huge"].(map[string]interface{}); !ok {
+		t.Errorf("huge = %#v, want an object", record["huge"])
+	}
+}
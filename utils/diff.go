@@ -0,0 +1,206 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// RuleChange describes a rule present in both sets whose definition differs.
+type RuleChange struct {
+	Key    string             `json:"key"`
+	Fields []string           `json:"fields"`
+	Before models.MappingRule `json:"before"`
+	After  models.MappingRule `json:"after"`
+}
+
+// RuleMove describes a rule present in both sets whose position changed
+// relative to the other rules. From and To are its indexes in each set.
+type RuleMove struct {
+	Key  string `json:"key"`
+	From int    `json:"from"`
+	To   int    `json:"to"`
+}
+
+// RuleSetDiff is the difference between two mapping rule sets.
+type RuleSetDiff struct {
+	Added   []models.MappingRule `json:"added"`
+	Removed []models.MappingRule `json:"removed"`
+	Changed []RuleChange         `json:"changed"`
+	Moved   []RuleMove           `json:"moved"`
+}
+
+// HasChanges reports whether the sets differ in any rule or in rule order.
+func (d RuleSetDiff) HasChanges() bool {
+	return len(d.Added)+len(d.Removed)+len(d.Changed)+len(d.Moved) > 0
+}
+
+// ruleMetadataFields are ignored when comparing rule definitions.
+var ruleMetadataFields = []string{"id", "client_id", "created_at", "updated_at"}
+
+// DiffRuleSets compares two rule sets. Rules are matched by destination path;
+// when several rules write the same destination they are matched in order.
+// Because later rules win, a change in order is reported too: the fewest
+// matched rules that must move to turn one order into the other are listed
+// as moved.
+func DiffRuleSets(from, to []models.MappingRule) RuleSetDiff {
+	diff := RuleSetDiff{
+		Added:   []models.MappingRule{},
+		Removed: []models.MappingRule{},
+		Changed: []RuleChange{},
+		Moved:   []RuleMove{},
+	}
+
+	fromKeys, toKeys := ruleKeys(from), ruleKeys(to)
+	fromByKey := keyRules(from, fromKeys)
+	toByKey := keyRules(to, toKeys)
+
+	for _, key := range sortedRuleKeys(fromByKey) {
+		before := fromByKey[key]
+		after, ok := toByKey[key]
+		if !ok {
+			diff.Removed = append(diff.Removed, before)
+			continue
+		}
+		if fields := changedRuleFields(before, after); len(fields) > 0 {
+			diff.Changed = append(diff.Changed, RuleChange{Key: key, Fields: fields, Before: before, After: after})
+		}
+	}
+	for _, key := range sortedRuleKeys(toByKey) {
+		if _, ok := fromByKey[key]; !ok {
+			diff.Added = append(diff.Added, toByKey[key])
+		}
+	}
+	diff.Moved = movedRules(fromKeys, toKeys)
+	return diff
+}
+
+// movedRules finds the keys present in both orders that are outside a
+// longest run of keys kept in the same relative order, sorted by key.
+func movedRules(fromKeys, toKeys []string) []RuleMove {
+	toIndex := make(map[string]int, len(toKeys))
+	for i, key := range toKeys {
+		toIndex[key] = i
+	}
+	var common []RuleMove
+	for i, key := range fromKeys {
+		if j, ok := toIndex[key]; ok {
+			common = append(common, RuleMove{Key: key, From: i, To: j})
+		}
+	}
+
+	// Longest increasing run of target indexes, in from order
+	length := make([]int, len(common))
+	prev := make([]int, len(common))
+	best := -1
+	for i := range common {
+		length[i], prev[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if common[j].To < common[i].To && length[j]+1 > length[i] {
+				length[i], prev[i] = length[j]+1, j
+			}
+		}
+		if best < 0 || length[i] > length[best] {
+			best = i
+		}
+	}
+	kept := make([]bool, len(common))
+	for i := best; i >= 0; i = prev[i] {
+		kept[i] = true
+	}
+
+	moved := []RuleMove{}
+	for i, move := range common {
+		if !kept[i] {
+			moved = append(moved, move)
+		}
+	}
+	sort.Slice(moved, func(i, j int) bool { return moved[i].Key < moved[j].Key })
+	return moved
+}
+
+// RuleKey identifies a rule by its dotted destination path, prefixed with
+// "set:" for rules in a named mapping set.
+func RuleKey(rule models.MappingRule) string {
+	key := strings.Join(rule.DestinationPath, ".")
+	if rule.Set != "" {
+		key = rule.Set + ":" + key
+	}
+	return key
+}
+
+// ruleKeys returns the RuleKey of each rule in order, suffixing repeats with
+// "#n" so duplicate destinations are still compared pairwise.
+func ruleKeys(rules []models.MappingRule) []string {
+	keys := make([]string, len(rules))
+	seen := make(map[string]int)
+	for i, rule := range rules {
+		key := RuleKey(rule)
+		seen[key]++
+		if seen[key] > 1 {
+			key += "#" + strconv.Itoa(seen[key])
+		}
+		keys[i] = key
+	}
+	return keys
+}
+
+// keyRules indexes rules by the keys ruleKeys returned for them.
+func keyRules(rules []models.MappingRule, keys []string) map[string]models.MappingRule {
+	keyed := make(map[string]models.MappingRule, len(rules))
+	for i, rule := range rules {
+		keyed[keys[i]] = rule
+	}
+	return keyed
+}
+
+func sortedRuleKeys(m map[string]models.MappingRule) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// changedRuleFields returns the JSON names of the definition fields that
+// differ between two rules.
+func changedRuleFields(a, b models.MappingRule) []string {
+	am, bm := ruleFields(a), ruleFields(b)
+	var fields []string
+	for _, key := range sortedKeys(am) {
+		if !reflect.DeepEqual(am[key], bm[key]) {
+			fields = append(fields, key)
+		}
+	}
+	for _, key := range sortedKeys(bm) {
+		if _, ok := am[key]; !ok {
+			fields = append(fields, key)
+		}
+	}
+	return fields
+}
+
+func ruleFields(rule models.MappingRule) map[string]interface{} {
+	var m map[string]interface{}
+	data, _ := json.Marshal(rule)
+	json.Unmarshal(data, &m)
+	stripRuleMetadata(m)
+	return m
+}
+
+// stripRuleMetadata removes the metadata fields from a rule and its sub-rules.
+func stripRuleMetadata(m map[string]interface{}) {
+	for _, field := range ruleMetadataFields {
+		delete(m, field)
+	}
+	subRules, _ := m["sub_rules"].([]interface{})
+	for _, sub := range subRules {
+		if sm, ok := sub.(map[string]interface{}); ok {
+			stripRuleMetadata(sm)
+		}
+	}
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func copyRule(source, destination string) models.MappingRule {
+	return models.MappingRule{
+		SourcePath:      strings.Split(source, "."),
+		DestinationPath: strings.Split(destination, "."),
+		TransformType:   "copy",
+	}
+}
+
+// diffSummary reduces a diff to the keys of added and removed rules and the
+// changed fields of each changed rule.
+func diffSummary(diff RuleSetDiff) (added, removed []string, changed map[string][]string) {
+	for _, rule := range diff.Added {
+		added = append(added, RuleKey(rule))
+	}
+	for _, rule := range diff.Removed {
+		removed = append(removed, RuleKey(rule))
+	}
+	for _, c := range diff.Changed {
+		if changed == nil {
+			changed = make(map[string][]string)
+		}
+		changed[c.Key] = c.Fields
+	}
+	return added, removed, changed
+}
+
+func TestDiffRuleSetsIgnoresMetadata(t *testing.T) {
+	stored := func(rule models.MappingRule, id uint) models.MappingRule {
+		rule.ID = id
+		rule.ClientID = 7
+		rule.CreatedAt = time.Unix(int64(id), 0)
+		rule.UpdatedAt = rule.CreatedAt
+		return rule
+	}
+	from := []models.MappingRule{stored(copyRule("loanId", "id"), 1), stored(copyRule("amount", "loan.amount"), 2)}
+	to := []models.MappingRule{stored(copyRule("loanId", "id"), 8), stored(copyRule("amount", "loan.amount"), 9)}
+
+	diff := DiffRuleSets(from, to)
+	if diff.HasChanges() {
+		t.Errorf("diff = %+v, want no differences", diff)
+	}
+	if diff.Added == nil || diff.Removed == nil || diff.Changed == nil || diff.Moved == nil {
+		t.Error("empty diff lists must be non-nil so they encode as []")
+	}
+}
+
+func TestDiffRuleSetsReportsMoves(t *testing.T) {
+	rules := func(destinations ...string) []models.MappingRule {
+		var list []models.MappingRule
+		for _, d := range destinations {
+			list = append(list, copyRule("src", d))
+		}
+		return list
+	}
+
+	for _, tc := range []struct {
+		name     string
+		from, to []models.MappingRule
+		want     []RuleMove
+	}{
+		{"swap", rules("a", "b"), rules("b", "a"), []RuleMove{{Key: "b", From: 1, To: 0}}},
+		{"first to last", rules("a", "b", "c", "d"), rules("b", "c", "d", "a"), []RuleMove{{Key: "a", From: 0, To: 3}}},
+		{"insert and remove shift nothing", rules("a", "b", "c"), rules("x", "a", "c"), []RuleMove{}},
+		{"two moved", rules("a", "b", "c", "d"), rules("d", "b", "c", "a"), []RuleMove{{Key: "a", From: 0, To: 3}, {Key: "d", From: 3, To: 0}}},
+	} {
+		diff := DiffRuleSets(tc.from, tc.to)
+		if !reflect.DeepEqual(diff.Moved, tc.want) {
+			t.Errorf("%s: moved = %+v, want %+v", tc.name, diff.Moved, tc.want)
+		}
+		if len(tc.want) > 0 && !diff.HasChanges() {
+			t.Errorf("%s: a reorder reported no changes", tc.name)
+		}
+	}
+}
+
+func TestDiffRuleSetsMatchesByDestination(t *testing.T) {
+	from := []models.MappingRule{
+		copyRule("loanId", "id"),
+		copyRule("pan", "kyc.pan"),
+		copyRule("gender", "applicant.gender"),
+	}
+	to := []models.MappingRule{
+		copyRule("loanId", "id"),
+		copyRule("pan", "kyc.panNumber"),
+		copyRule("sex", "applicant.gender"),
+	}
+	to[2].TransformType = "mapGender"
+
+	added, removed, changed := diffSummary(DiffRuleSets(from, to))
+	if !reflect.DeepEqual(added, []string{"kyc.panNumber"}) {
+		t.Errorf("added = %v", added)
+	}
+	if !reflect.DeepEqual(removed, []string{"kyc.pan"}) {
+		t.Errorf("removed = %v", removed)
+	}
+	want := map[string][]string{"applicant.gender": {"source_path", "transform_type"}}
+	if !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestDiffRuleSetsPairsDuplicateDestinations(t *testing.T) {
+	from := []models.MappingRule{copyRule("a", "out"), copyRule("b", "out")}
+	to := []models.MappingRule{copyRule("a", "out"), copyRule("c", "out"), copyRule("d", "out")}
+
+	diff := DiffRuleSets(from, to)
+	added, removed, changed := diffSummary(diff)
+	if len(added) != 1 || diff.Added[0].SourcePath[0] != "d" {
+		t.Errorf("added = %+v, want the third rule writing out", diff.Added)
+	}
+	if removed != nil {
+		t.Errorf("removed = %v, want none", removed)
+	}
+	if want := map[string][]string{"out#2": {"source_path"}}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestDiffRuleSetsComparesSubRules(t *testing.T) {
+	group := func(city string, id uint) models.MappingRule {
+		sub := copyRule(city, "city")
+		sub.ID = id
+		sub.CreatedAt = time.Unix(int64(id), 0)
+		rule := copyRule("addresses", "addresses")
+		rule.TransformType = "ungroup"
+		rule.SubRules = []models.MappingRule{sub}
+		return rule
+	}
+
+	if diff := DiffRuleSets([]models.MappingRule{group("city", 1)}, []models.MappingRule{group("city", 2)}); len(diff.Changed) != 0 {
+		t.Errorf("sub-rule metadata reported as a change: %+v", diff.Changed)
+	}
+	_, _, changed := diffSummary(DiffRuleSets([]models.MappingRule{group("city", 1)}, []models.MappingRule{group("town", 1)}))
+	if want := map[string][]string{"addresses": {"sub_rules"}}; !reflect.DeepEqual(changed, want) {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+}
+
+func TestDiffRuleSetsKeysBySet(t *testing.T) {
+	secured := copyRule("loanId", "id")
+	secured.Set = "secured"
+
+	added, removed, changed := diffSummary(DiffRuleSets([]models.MappingRule{secured}, []models.MappingRule{copyRule("loanId", "id")}))
+	if !reflect.DeepEqual(added, []string{"id"}) || !reflect.DeepEqual(removed, []string{"secured:id"}) || changed != nil {
+		t.Errorf("moving a rule between sets: added %v, removed %v, changed %v", added, removed, changed)
+	}
+}
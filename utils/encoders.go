@@ -0,0 +1,367 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Encoder serializes transformation results into a wire format. Encode
+// receives either a single output document or a slice of documents.
+type Encoder interface {
+	ContentType() string
+	Encode(w io.Writer, data interface{}) error
+}
+
+// outputMediaTypes maps Accept header media types to output format names.
+var outputMediaTypes = map[string]string{
+	"application/json":       "json",
+	"application/x-ndjson":   "ndjson",
+	"application/xml":        "xml",
+	"text/xml":               "xml",
+	"text/csv":               "csv",
+	"text/x-java-properties": "properties",
+	"text/x-fixed-width":     "fixedwidth",
+}
+
+// FormatFromAccept returns the output format for the first media type in an
+// Accept header that has a registered encoder, or "" when none does.
+func FormatFromAccept(accept string) string {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		if format, ok := outputMediaTypes[mediaType]; ok {
+			return format
+		}
+	}
+	return ""
+}
+
+// NewEncoder returns the encoder for an output format name. An empty format
+// selects JSON.
+func NewEncoder(format string, opts models.OutputOptions) (Encoder, error) {
+	switch format {
+	case "", "json":
+		return JSONEncoder{}, nil
+	case "ndjson":
+		return NDJSONEncoder{}, nil
+	case "xml":
+		return XMLEncoder{RootName: opts.XMLRootName, ItemName: opts.XMLItemName}, nil
+	case "csv":
+		delimiter := ','
+		if opts.CSVDelimiter != "" {
+			delimiter, _ = utf8.DecodeRuneInString(opts.CSVDelimiter)
+		}
+		return CSVEncoder{Delimiter: delimiter}, nil
+	case "properties":
+		return PropertiesEncoder{}, nil
+	case "fixedwidth":
+		if opts.FixedWidth == nil {
+			return nil, fmt.Errorf("output format %q requires a fixed-width layout", format)
+		}
+		return &FixedWidthEncoder{Layout: *opts.FixedWidth}, nil
+	default:
+		return nil, fmt.Errorf("unsupported output format %q", format)
+	}
+}
+
+// JSONEncoder writes data as a single JSON document.
+type JSONEncoder struct{}
+
+func (JSONEncoder) ContentType() string { return "application/json; charset=utf-8" }
+
+func (JSONEncoder) Encode(w io.Writer, data interface{}) error {
+	return json.NewEncoder(w).Encode(data)
+}
+
+// NDJSONEncoder writes one JSON document per line.
+type NDJSONEncoder struct{}
+
+func (NDJSONEncoder) ContentType() string { return "application/x-ndjson" }
+
+func (NDJSONEncoder) Encode(w io.Writer, data interface{}) error {
+	enc := json.NewEncoder(w)
+	for _, doc := range documentList(data) {
+		if err := enc.Encode(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// XMLEncoder writes data as an XML document. Object keys become element names
+// in sorted order, array entries are wrapped in ItemName elements and a list of documents is
+// wrapped in a single RootName element.
+type XMLEncoder struct {
+	RootName string
+	ItemName string
+}
+
+func (XMLEncoder) ContentType() string { return "application/xml; charset=utf-8" }
+
+func (e XMLEncoder) Encode(w io.Writer, data interface{}) error {
+	root := e.RootName
+	if root == "" {
+		root = "root"
+	}
+	item := e.ItemName
+	if item == "" {
+		item = "item"
+	}
+
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	writeXMLElement(&b, xmlName(root), normalizeDocuments(data), xmlName(item))
+	b.WriteString("\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeXMLElement(b *strings.Builder, name string, value interface{}, item string) {
+	b.WriteString("<" + name + ">")
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			writeXMLElement(b, xmlName(key), v[key], item)
+		}
+	case []interface{}:
+		for _, elem := range v {
+			writeXMLElement(b, item, elem, item)
+		}
+	default:
+		xml.EscapeText(stringWriter{b}, []byte(FormatScalar(v)))
+	}
+	b.WriteString("</" + name + ">")
+}
+
+// xmlName turns an arbitrary object key into a valid XML element name.
+func xmlName(key string) string {
+	var b strings.Builder
+	for i, r := range key {
+		valid := unicode.IsLetter(r) || r == '_' || (i > 0 && (unicode.IsDigit(r) || r == '-' || r == '.'))
+		if valid {
+			b.WriteRune(r)
+		} else {
+			b.WriteRune('_')
+		}
+	}
+	name := b.String()
+	if name == "" || strings.HasPrefix(strings.ToLower(name), "xml") {
+		name = "_" + name
+	}
+	return name
+}
+
+type stringWriter struct{ b *strings.Builder }
+
+func (s stringWriter) Write(p []byte) (int, error) { return s.b.Write(p) }
+
+// CSVEncoder writes one row per document with flattened dotted column names,
+// in sorted order.
+type CSVEncoder struct {
+	Delimiter rune
+}
+
+func (CSVEncoder) ContentType() string { return "text/csv; charset=utf-8" }
+
+func (e CSVEncoder) Encode(w io.Writer, data interface{}) error {
+	docs := documentList(data)
+	rows := make([]map[string]interface{}, len(docs))
+	columnSet := make(map[string]bool)
+	for i, doc := range docs {
+		rows[i] = FlattenPaths(doc)
+		for col := range rows[i] {
+			columnSet[col] = true
+		}
+	}
+	columns := make([]string, 0, len(columnSet))
+	for col := range columnSet {
+		columns = append(columns, col)
+	}
+	sortPaths(columns)
+
+	cw := csv.NewWriter(w)
+	if e.Delimiter != 0 {
+		cw.Comma = e.Delimiter
+	}
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			record[i] = FormatScalar(row[col])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// PropertiesEncoder writes flattened key=value lines sorted by key. Documents
+// in a list are prefixed with their index.
+type PropertiesEncoder struct{}
+
+func (PropertiesEncoder) ContentType() string { return "text/x-java-properties; charset=utf-8" }
+
+func (PropertiesEncoder) Encode(w io.Writer, data interface{}) error {
+	flat := FlattenPaths(normalizeDocuments(data))
+	keys := make([]string, 0, len(flat))
+	for key := range flat {
+		keys = append(keys, key)
+	}
+	sortPaths(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		b.WriteString(escapeProperty(key, true))
+		b.WriteString("=")
+		b.WriteString(escapeProperty(FormatScalar(flat[key]), false))
+		b.WriteString("\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func escapeProperty(s string, isKey bool) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '=', ':', ' ', '#', '!':
+			if isKey {
+				b.WriteRune('\\')
+			}
+			b.WriteRune(r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// FlattenPaths flattens nested maps and arrays into dotted paths mapped to
+// their scalar values. Empty objects and arrays are kept as nil entries.
+func FlattenPaths(data interface{}) map[string]interface{} {
+	flat := make(map[string]interface{})
+	flattenInto(flat, "", data)
+	return flat
+}
+
+func flattenInto(flat map[string]interface{}, prefix string, value interface{}) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if len(v) == 0 && prefix != "" {
+			flat[prefix] = nil
+		}
+		for key, elem := range v {
+			flattenInto(flat, join(key), elem)
+		}
+	case []interface{}:
+		if len(v) == 0 && prefix != "" {
+			flat[prefix] = nil
+		}
+		for i, elem := range v {
+			flattenInto(flat, join(strconv.Itoa(i)), elem)
+		}
+	default:
+		flat[prefix] = v
+	}
+}
+
+// FormatScalar renders a decoded JSON scalar as plain text. Whole numbers are
+// written without an exponent and nil becomes an empty string.
+func FormatScalar(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	case map[string]interface{}, []interface{}:
+		b, _ := json.Marshal(val)
+		return string(b)
+	default:
+		return fmt.Sprintf("%v", val)
+	}
+}
+
+// documentList returns data as a list of documents.
+func documentList(data interface{}) []interface{} {
+	switch v := normalizeDocuments(data).(type) {
+	case []interface{}:
+		return v
+	default:
+		return []interface{}{v}
+	}
+}
+
+// normalizeDocuments converts typed document slices to []interface{} so the
+// encoders only deal with decoded JSON shapes.
+func normalizeDocuments(data interface{}) interface{} {
+	if docs, ok := data.([]map[string]interface{}); ok {
+		list := make([]interface{}, len(docs))
+		for i, doc := range docs {
+			list[i] = doc
+		}
+		return list
+	}
+	return data
+}
+
+// sortPaths sorts dotted paths segment by segment, comparing array indices
+// as numbers so that items.2 comes before items.10.
+func sortPaths(paths []string) {
+	sort.Slice(paths, func(i, j int) bool {
+		a, b := strings.Split(paths[i], "."), strings.Split(paths[j], ".")
+		for k := 0; k < len(a) && k < len(b); k++ {
+			if a[k] == b[k] {
+				continue
+			}
+			x, errX := strconv.Atoi(a[k])
+			y, errY := strconv.Atoi(b[k])
+			if errX == nil && errY == nil {
+				return x < y
+			}
+			return a[k] < b[k]
+		}
+		return len(a) < len(b)
+	})
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
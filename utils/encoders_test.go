@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// failingWriter rejects every write, standing in for a client that went away.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, errors.New("connection reset") }
+
+func TestNewEncoder(t *testing.T) {
+	if enc, err := NewEncoder("", models.OutputOptions{}); err != nil {
+		t.Fatalf("NewEncoder(\"\"): %v", err)
+	} else if _, ok := enc.(JSONEncoder); !ok {
+		t.Errorf("NewEncoder(\"\") = %T, want JSONEncoder", enc)
+	}
+
+	enc, err := NewEncoder("csv", models.OutputOptions{CSVDelimiter: ";"})
+	if err != nil {
+		t.Fatalf("NewEncoder(csv): %v", err)
+	}
+	if got := enc.(CSVEncoder).Delimiter; got != ';' {
+		t.Errorf("CSV delimiter = %q, want ';'", got)
+	}
+
+	if _, err := NewEncoder("yaml", models.OutputOptions{}); err == nil {
+		t.Error("NewEncoder(yaml) succeeded, want an unsupported format error")
+	}
+}
+
+func TestFormatFromAccept(t *testing.T) {
+	cases := map[string]string{
+		"application/json":                       "json",
+		"text/html, application/xml;q=0.9":       "xml",
+		"text/csv; charset=utf-8":                "csv",
+		"application/x-ndjson, application/json": "ndjson",
+		"text/x-java-properties":                 "properties",
+		"text/html, */*":                         "",
+		"":                                       "",
+	}
+	for accept, want := range cases {
+		if got := FormatFromAccept(accept); got != want {
+			t.Errorf("FormatFromAccept(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestEncodersReturnWriteErrors(t *testing.T) {
+	doc := map[string]interface{}{"id": "L1"}
+	for _, format := range []string{"json", "ndjson", "xml", "csv", "properties"} {
+		enc, err := NewEncoder(format, models.OutputOptions{})
+		if err != nil {
+			t.Fatalf("NewEncoder(%s): %v", format, err)
+		}
+		if err := enc.Encode(failingWriter{}, doc); err == nil {
+			t.Errorf("%s encoder ignored a failed write", format)
+		}
+	}
+}
+
+func TestXMLEncoder(t *testing.T) {
+	data := mustJSON(t, `{"b": 1, "a": {"x y": "<&>", "1st": true, "xmlData": null}, "list": [1, 2]}`)
+
+	// Elements follow the sorted object keys, not the sanitized names
+	var b strings.Builder
+	if err := (XMLEncoder{}).Encode(&b, data); err != nil {
+		t.Fatal(err)
+	}
+	want := `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+		`<root><a><_st>true</_st><x_y>&lt;&amp;&gt;</x_y><_xmlData></_xmlData></a><b>1</b>` +
+		`<list><item>1</item><item>2</item></list></root>` + "\n"
+	if b.String() != want {
+		t.Errorf("XML =\n%s\nwant\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	docs := []map[string]interface{}{{"id": "L1"}, {"id": "L2"}}
+	if err := (XMLEncoder{RootName: "loans", ItemName: "loan"}).Encode(&b, docs); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "<loans><loan><id>L1</id></loan><loan><id>L2</id></loan></loans>") {
+		t.Errorf("XML list = %s", b.String())
+	}
+}
+
+func TestCSVEncoderUnionsColumns(t *testing.T) {
+	docs := []map[string]interface{}{
+		mustJSON(t, `{"id": 1, "applicant": {"name": "Asha, K"}}`).(map[string]interface{}),
+		mustJSON(t, `{"id": 2, "tags": []}`).(map[string]interface{}),
+	}
+	var b strings.Builder
+	if err := (CSVEncoder{}).Encode(&b, docs); err != nil {
+		t.Fatal(err)
+	}
+	want := "applicant.name,id,tags\n\"Asha, K\",1,\n,2,\n"
+	if b.String() != want {
+		t.Errorf("CSV = %q, want %q", b.String(), want)
+	}
+}
+
+func TestPropertiesEncoder(t *testing.T) {
+	var b strings.Builder
+	doc := mustJSON(t, `{"a b": "x=y", "n": {"k": "line\nbreak"}}`)
+	if err := (PropertiesEncoder{}).Encode(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	if want := "a\\ b=x=y\nn.k=line\\nbreak\n"; b.String() != want {
+		t.Errorf("properties = %q, want %q", b.String(), want)
+	}
+
+	b.Reset()
+	docs := []map[string]interface{}{{"id": "L1"}, {"id": "L2"}}
+	if err := (PropertiesEncoder{}).Encode(&b, docs); err != nil {
+		t.Fatal(err)
+	}
+	if want := "0.id=L1\n1.id=L2\n"; b.String() != want {
+		t.Errorf("properties list = %q, want %q", b.String(), want)
+	}
+}
+
+func TestFlattenedKeysSortNaturally(t *testing.T) {
+	items := make([]interface{}, 11)
+	for i := range items {
+		items[i] = map[string]interface{}{"n": float64(i)}
+	}
+	doc := map[string]interface{}{"items": items, "id": "L1"}
+
+	var b strings.Builder
+	if err := (PropertiesEncoder{}).Encode(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	if lines[0] != "id=L1" || lines[2] != "items.1.n=1" || lines[3] != "items.2.n=2" || lines[11] != "items.10.n=10" {
+		t.Errorf("properties order:\n%s", b.String())
+	}
+
+	b.Reset()
+	if err := (CSVEncoder{}).Encode(&b, doc); err != nil {
+		t.Fatal(err)
+	}
+	header := strings.SplitN(b.String(), "\n", 2)[0]
+	if !strings.HasPrefix(header, "id,items.0.n,items.1.n,items.2.n,") || !strings.HasSuffix(header, "items.9.n,items.10.n") {
+		t.Errorf("CSV header = %s", header)
+	}
+}
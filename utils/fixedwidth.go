@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// WarningReporter is implemented by encoders that can report non-fatal
+// problems, such as truncated values, after Encode has run.
+type WarningReporter interface {
+	Warnings() []string
+}
+
+// ErrFixedWidthOverflow is wrapped by the error Encode returns when a
+// zero-padded number is wider than its column.
+var ErrFixedWidthOverflow = errors.New("numeric value overflows its column")
+
+// FixedWidthEncoder writes one fixed-width record per output document using
+// the client's layout. Values that do not fit their column are truncated and
+// reported through Warnings, except zero-padded numbers: a truncated number
+// reads as a different amount, so Encode fails with ErrFixedWidthOverflow
+// instead.
+type FixedWidthEncoder struct {
+	Layout   models.FixedWidthLayout
+	warnings []string
+}
+
+func (*FixedWidthEncoder) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (e *FixedWidthEncoder) Warnings() []string { return e.warnings }
+
+func (e *FixedWidthEncoder) Encode(w io.Writer, data interface{}) error {
+	separator := e.Layout.RecordSeparator
+	if separator == "" {
+		separator = "\n"
+	}
+
+	e.warnings = nil
+	var b strings.Builder
+	for n, doc := range documentList(data) {
+		docMap, _ := doc.(map[string]interface{})
+		for _, field := range e.Layout.Fields {
+			var value interface{}
+			if docMap != nil {
+				value, _ = GetNestedValue(docMap, strings.Split(field.Path, "."))
+			}
+			cell, warning, err := formatFixedWidthField(field, value)
+			if err != nil {
+				return fmt.Errorf("record %d field %s: %w", n+1, field.Path, err)
+			}
+			if warning != "" {
+				e.warnings = append(e.warnings, fmt.Sprintf("record %d field %s: %s", n+1, field.Path, warning))
+			}
+			b.WriteString(cell)
+		}
+		b.WriteString(separator)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// formatFixedWidthField pads or truncates a value to the field's width and
+// returns a warning describing any loss of data. A zero-padded number that
+// does not fit is an error.
+func formatFixedWidthField(field models.FixedWidthField, value interface{}) (string, string, error) {
+	text := FormatScalar(value)
+	var warning string
+
+	if field.ZeroPad && text != "" {
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			warning = fmt.Sprintf("value %q is not numeric and was not zero-padded", text)
+		} else {
+			sign := ""
+			if strings.HasPrefix(text, "-") {
+				sign, text = "-", text[1:]
+			}
+			if width := field.Width - len(sign); utf8.RuneCountInString(text) < width {
+				text = strings.Repeat("0", width-utf8.RuneCountInString(text)) + text
+			}
+			text = sign + text
+		}
+	}
+
+	if length := utf8.RuneCountInString(text); length > field.Width {
+		if field.ZeroPad && warning == "" {
+			return "", "", fmt.Errorf("%w: %s needs %d characters, width is %d", ErrFixedWidthOverflow, text, length, field.Width)
+		}
+		warning = fmt.Sprintf("value truncated from %d to %d characters", length, field.Width)
+		return string([]rune(text)[:field.Width]), warning, nil
+	}
+
+	pad := field.PadChar
+	if pad == "" {
+		pad = " "
+	}
+	padding := strings.Repeat(pad, field.Width-utf8.RuneCountInString(text))
+	if field.Align == "right" {
+		return padding + text, warning, nil
+	}
+	return text + padding, warning, nil
+}
+
+// ValidateFixedWidthLayout checks that every field has a path, a positive
+// width, a single padding character and a known alignment.
+func ValidateFixedWidthLayout(layout models.FixedWidthLayout) error {
+	if len(layout.Fields) == 0 {
+		return fmt.Errorf("fixed-width layout must define at least one field")
+	}
+	for i, field := range layout.Fields {
+		if field.Path == "" {
+			return fmt.Errorf("fixed-width field %d: path is required", i)
+		}
+		if field.Width <= 0 {
+			return fmt.Errorf("fixed-width field %d (%s): width must be positive", i, field.Path)
+		}
+		if field.PadChar != "" && utf8.RuneCountInString(field.PadChar) != 1 {
+			return fmt.Errorf("fixed-width field %d (%s): pad_char must be a single character", i, field.Path)
+		}
+		if field.Align != "" && field.Align != "left" && field.Align != "right" {
+			return fmt.Errorf("fixed-width field %d (%s): align must be 'left' or 'right'", i, field.Path)
+		}
+	}
+	return nil
+}
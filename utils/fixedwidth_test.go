@@ -0,0 +1,150 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFixedWidthEncoder(t *testing.T) {
+	layout := models.FixedWidthLayout{
+		Fields: []models.FixedWidthField{
+			{Path: "loan.id", Width: 5},
+			{Path: "name", Width: 6, Align: "right", PadChar: "*"},
+			{Path: "amount", Width: 8, ZeroPad: true},
+		},
+	}
+
+	tests := []struct {
+		name         string
+		layout       models.FixedWidthLayout
+		data         interface{}
+		want         string
+		wantWarnings []string
+	}{
+		{
+			name:   "padding and alignment",
+			layout: layout,
+			data:   mustJSON(t, `{"loan": {"id": "L1"}, "name": "Asha", "amount": -42.5}`),
+			want:   "L1   **Asha-00042.5\n",
+		},
+		{
+			name:   "missing values are blank",
+			layout: layout,
+			data:   mustJSON(t, `{"name": null}`),
+			want:   "     ******        \n",
+		},
+		{
+			name:   "width counts characters",
+			layout: layout,
+			data:   mustJSON(t, `{"loan": {"id": "São"}, "name": "Zoë", "amount": 7}`),
+			want:   "São  ***Zoë00000007\n",
+		},
+		{
+			name:   "truncation is reported",
+			layout: layout,
+			data:   mustJSON(t, `{"loan": {"id": "L1"}, "name": "Subramanian", "amount": 1234}`),
+			want:   "L1   Subram00001234\n",
+			wantWarnings: []string{
+				"record 1 field name: value truncated from 11 to 6 characters",
+			},
+		},
+		{
+			name:         "non-numeric value is not zero-padded",
+			layout:       layout,
+			data:         mustJSON(t, `{"loan": {"id": "L1"}, "name": "Asha", "amount": "n/a"}`),
+			want:         "L1   **Ashan/a     \n",
+			wantWarnings: []string{`record 1 field amount: value "n/a" is not numeric and was not zero-padded`},
+		},
+		{
+			name: "one record per document with a custom separator",
+			layout: models.FixedWidthLayout{
+				Fields:          []models.FixedWidthField{{Path: "id", Width: 3, Align: "right", PadChar: "0"}, {Path: "ok", Width: 5}},
+				RecordSeparator: "\r\n",
+			},
+			data: []map[string]interface{}{
+				{"id": 1.0, "ok": true},
+				{"id": 1234.0, "ok": false},
+			},
+			want:         "001true \r\n123false\r\n",
+			wantWarnings: []string{"record 2 field id: value truncated from 4 to 3 characters"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			enc := &FixedWidthEncoder{Layout: tt.layout}
+			var b strings.Builder
+			if err := enc.Encode(&b, tt.data); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			if b.String() != tt.want {
+				t.Errorf("output = %q, want %q", b.String(), tt.want)
+			}
+			if !reflect.DeepEqual(enc.Warnings(), tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", enc.Warnings(), tt.wantWarnings)
+			}
+		})
+	}
+}
+
+// A zero-padded number is never truncated, since the result would read as a
+// different amount.
+func TestFixedWidthEncoderNumericOverflow(t *testing.T) {
+	enc := &FixedWidthEncoder{Layout: models.FixedWidthLayout{
+		Fields: []models.FixedWidthField{{Path: "id", Width: 2}, {Path: "amount", Width: 5, ZeroPad: true}},
+	}}
+	for _, amount := range []interface{}{123456.0, -12345.0, "1.23456"} {
+		var b strings.Builder
+		err := enc.Encode(&b, []map[string]interface{}{{"id": "1", "amount": 1.0}, {"id": "2", "amount": amount}})
+		if !errors.Is(err, ErrFixedWidthOverflow) || !strings.HasPrefix(err.Error(), "record 2 field amount: ") {
+			t.Errorf("amount %v: err = %v, want an overflow in record 2", amount, err)
+		}
+		if b.Len() != 0 {
+			t.Errorf("amount %v: wrote %q before failing", amount, b.String())
+		}
+	}
+}
+
+func TestFixedWidthEncoderResetsWarnings(t *testing.T) {
+	enc := &FixedWidthEncoder{Layout: models.FixedWidthLayout{
+		Fields: []models.FixedWidthField{{Path: "id", Width: 2}},
+	}}
+	var b strings.Builder
+	if err := enc.Encode(&b, map[string]interface{}{"id": "long"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(enc.Warnings()) != 1 {
+		t.Fatalf("warnings = %q, want one", enc.Warnings())
+	}
+	if err := enc.Encode(&b, map[string]interface{}{"id": "ok"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(enc.Warnings()) != 0 {
+		t.Errorf("warnings = %q after a clean encode, want none", enc.Warnings())
+	}
+}
+
+func TestValidateFixedWidthLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		fields  []models.FixedWidthField
+		wantErr bool
+	}{
+		{"valid", []models.FixedWidthField{{Path: "id", Width: 3, PadChar: "0", Align: "right"}}, false},
+		{"no fields", nil, true},
+		{"missing path", []models.FixedWidthField{{Width: 3}}, true},
+		{"zero width", []models.FixedWidthField{{Path: "id"}}, true},
+		{"long pad char", []models.FixedWidthField{{Path: "id", Width: 3, PadChar: "--"}}, true},
+		{"unknown alignment", []models.FixedWidthField{{Path: "id", Width: 3, Align: "center"}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateFixedWidthLayout(models.FixedWidthLayout{Fields: tt.fields})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFixedWidthLayout error = %v, want error %v", err, tt.wantErr)
+			}
+		})
+	}
+}
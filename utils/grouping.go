@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+)
+
+// defaultGroupTag is the field ungroup writes the group key into when the
+// rule's transform_logic does not name one.
+const defaultGroupTag = "group"
+
+// applyGrouping runs a group or ungroup rule on the value found at its
+// source path.
+//
+// group turns an array into an object of arrays keyed by the rule's
+// transform_logic, an expression evaluated with value bound to each element
+// and index to its position. ungroup is the reverse: it flattens an object of
+// arrays into one array, writing each element's key into the field named
+// by transform_logic. In both directions the rule's sub-rules, if any,
+// map each object element with the element as their input.
+func applyGrouping(rule models.MappingRule, value interface{}, input, root map[string]interface{}) (interface{}, error) {
+	switch rule.TransformType {
+	case "group":
+		elems, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("group source %v is not an array", rule.SourcePath)
+		}
+		groups := make(map[string]interface{})
+		for i, elem := range elems {
+			key, err := EvaluateExpression(rule.TransformLogic, map[string]interface{}{
+				"value": elem,
+				"index": i,
+				"input": input,
+				"root":  root,
+			})
+			if err != nil {
+				return nil, err
+			}
+			name := FormatScalar(key)
+			members, _ := groups[name].([]interface{})
+			groups[name] = append(members, mapElement(elem, rule.SubRules, root))
+		}
+		return groups, nil
+
+	case "ungroup":
+		groups, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("ungroup source %v is not an object", rule.SourcePath)
+		}
+		tag := rule.TransformLogic
+		if tag == "" {
+			tag = defaultGroupTag
+		}
+		flat := []interface{}{}
+		for _, name := range sortedKeys(groups) {
+			members := toList(groups[name])
+			if members == nil && groups[name] != nil {
+				members = []interface{}{groups[name]}
+			}
+			for _, elem := range members {
+				if m, ok := elem.(map[string]interface{}); ok {
+					tagged := make(map[string]interface{}, len(m)+1)
+					for k, v := range m {
+						tagged[k] = v
+					}
+					tagged[tag] = name
+					elem = tagged
+				}
+				flat = append(flat, mapElement(elem, rule.SubRules, root))
+			}
+		}
+		return flat, nil
+	}
+	return nil, fmt.Errorf("unsupported grouping transform %q", rule.TransformType)
+}
+
+// mapElement applies sub-rules to an object element. Elements that are not
+// objects, and all elements when there are no sub-rules, are kept as they are.
+func mapElement(elem interface{}, subRules []models.MappingRule, root map[string]interface{}) interface{} {
+	m, ok := elem.(map[string]interface{})
+	if !ok || len(subRules) == 0 {
+		return elem
+	}
+	return applyRules(m, root, subRules)
+}
+
+// applySubMapping applies the rules of the sub-mapping a subMapping rule names
+// to each element of its source array, or to the source itself when it is a
+// single object. The handlers resolve the name into the rule's SubRules
+// before transforming.
+func applySubMapping(rule models.MappingRule, value interface{}, root map[string]interface{}) (interface{}, error) {
+	if len(rule.SubRules) == 0 {
+		return nil, fmt.Errorf("sub-mapping %q is not loaded", rule.TransformLogic)
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return applyRules(v, root, rule.SubRules), nil
+	case []interface{}:
+		mapped := make([]interface{}, len(v))
+		for i, elem := range v {
+			mapped[i] = mapElement(elem, rule.SubRules, root)
+		}
+		return mapped, nil
+	}
+	return nil, fmt.Errorf("sub-mapping source %v is not an array or object", rule.SourcePath)
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"sort"
+)
+
+// UnmatchedRecord is a record that took part in a join without finding a
+// partner: a base record with no match in the document named by MissingIn,
+// a record of another document that matches no base record, or a record
+// without a join key. Index is the record's position in its document.
+type UnmatchedRecord struct {
+	Document  string      `json:"document"`
+	Index     int         `json:"index"`
+	Key       interface{} `json:"key,omitempty"`
+	MissingIn string      `json:"missing_in,omitempty"`
+	Reason    string      `json:"reason"`
+}
+
+// joinRecord is an object record of a document with its position.
+type joinRecord struct {
+	index  int
+	key    interface{}
+	record map[string]interface{}
+}
+
+// MergeDocuments builds one input tree from named documents, each under its
+// name. With a join spec, every record of the base document also gets, under
+// each other document's name, the array of that document's records whose
+// key equals its own. Keys are compared by their text, so 5571 matches
+// "5571". Records that do not join are returned alongside the tree.
+func MergeDocuments(documents map[string]interface{}, join *models.JoinSpec) (map[string]interface{}, []UnmatchedRecord, error) {
+	tree := make(map[string]interface{}, len(documents))
+	for name, document := range documents {
+		tree[name] = document
+	}
+	if join == nil {
+		return tree, nil, nil
+	}
+
+	base, ok := documents[join.Base]
+	if !ok {
+		return nil, nil, fmt.Errorf("join base document %q not found", join.Base)
+	}
+	for name := range join.Keys {
+		if _, ok := documents[name]; !ok {
+			return nil, nil, fmt.Errorf("join key given for unknown document %q", name)
+		}
+	}
+	keyPath := func(name string) []string {
+		if path, ok := join.Keys[name]; ok {
+			return models.ParsePath(path)
+		}
+		return models.ParsePath(join.On)
+	}
+
+	var unmatched []UnmatchedRecord
+	var others []string
+	byKey := make(map[string]map[string][]joinRecord)
+	for _, name := range sortedKeys(documents) {
+		if name == join.Base {
+			continue
+		}
+		others = append(others, name)
+		byKey[name] = make(map[string][]joinRecord)
+		records, missing := joinRecords(name, documents[name], keyPath(name))
+		unmatched = append(unmatched, missing...)
+		for _, r := range records {
+			k := FormatScalar(r.key)
+			byKey[name][k] = append(byKey[name][k], r)
+		}
+	}
+
+	var baseRecords []joinRecord
+	switch base.(type) {
+	case map[string]interface{}, []interface{}:
+		var missing []UnmatchedRecord
+		baseRecords, missing = joinRecords(join.Base, base, keyPath(join.Base))
+		unmatched = append(unmatched, missing...)
+	default:
+		return nil, nil, fmt.Errorf("join base document %q must be an object or an array", join.Base)
+	}
+
+	used := make(map[string]map[int]bool, len(others))
+	for _, name := range others {
+		used[name] = make(map[int]bool)
+	}
+	merged := make(map[int]map[string]interface{}, len(baseRecords))
+	for _, r := range baseRecords {
+		record := make(map[string]interface{}, len(r.record)+len(others))
+		for k, v := range r.record {
+			record[k] = v
+		}
+		for _, name := range others {
+			matches := byKey[name][FormatScalar(r.key)]
+			list := make([]interface{}, len(matches))
+			for j, match := range matches {
+				list[j] = match.record
+				used[name][match.index] = true
+			}
+			record[name] = list
+			if len(matches) == 0 {
+				unmatched = append(unmatched, UnmatchedRecord{
+					Document:  join.Base,
+					Index:     r.index,
+					Key:       r.key,
+					MissingIn: name,
+					Reason:    "no matching " + name + " record",
+				})
+			}
+		}
+		merged[r.index] = record
+	}
+
+	for _, name := range others {
+		for _, records := range byKey[name] {
+			for _, r := range records {
+				if !used[name][r.index] {
+					unmatched = append(unmatched, UnmatchedRecord{
+						Document: name,
+						Index:    r.index,
+						Key:      r.key,
+						Reason:   "no matching " + join.Base + " record",
+					})
+				}
+			}
+		}
+	}
+	sort.SliceStable(unmatched, func(i, j int) bool {
+		a, b := unmatched[i], unmatched[j]
+		if a.Document != b.Document {
+			return a.Document < b.Document
+		}
+		if a.Index != b.Index {
+			return a.Index < b.Index
+		}
+		return a.MissingIn < b.MissingIn
+	})
+
+	switch v := base.(type) {
+	case map[string]interface{}:
+		if record, ok := merged[0]; ok {
+			tree[join.Base] = record
+		}
+	case []interface{}:
+		joined := make([]interface{}, len(v))
+		for i, elem := range v {
+			if record, ok := merged[i]; ok {
+				joined[i] = record
+			} else {
+				joined[i] = elem
+			}
+		}
+		tree[join.Base] = joined
+	}
+	return tree, unmatched, nil
+}
+
+// joinRecords returns the keyed object records of a document, which is an
+// object or an array of objects, and reports the records without a key.
+// Other values have no records.
+func joinRecords(name string, document interface{}, keyPath []string) ([]joinRecord, []UnmatchedRecord) {
+	var elems []interface{}
+	switch v := document.(type) {
+	case map[string]interface{}:
+		elems = []interface{}{v}
+	case []interface{}:
+		elems = v
+	}
+
+	var records []joinRecord
+	var missing []UnmatchedRecord
+	for i, elem := range elems {
+		record, ok := elem.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		key, ok := GetNestedValue(record, keyPath)
+		if !ok || key == nil {
+			missing = append(missing, UnmatchedRecord{Document: name, Index: i, Reason: "missing join key"})
+			continue
+		}
+		records = append(records, joinRecord{index: i, key: key, record: record})
+	}
+	return records, missing
+}
@@ -0,0 +1,102 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"reflect"
+	"testing"
+)
+
+func TestMergeDocumentsWithoutJoin(t *testing.T) {
+	docs := mustJSON(t, `{"loan": {"loanId": 1}, "bureau": [{"score": 700}]}`).(map[string]interface{})
+	tree, unmatched, err := MergeDocuments(docs, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(tree, docs) || unmatched != nil {
+		t.Errorf("tree = %v, unmatched = %v; want the documents by name and no unmatched records", tree, unmatched)
+	}
+}
+
+func TestMergeDocumentsJoinsRecords(t *testing.T) {
+	docs := mustJSON(t, `{
+		"loans": [
+			{"loanId": 5571, "amount": 100},
+			{"loanId": "5572", "amount": 200},
+			{"amount": 300}
+		],
+		"applicants": [
+			{"loanId": "5571", "name": "Asha"},
+			{"loanId": 5571, "name": "Ravi"},
+			{"loanId": 9999, "name": "Meera"}
+		],
+		"collateral": [
+			{"ref": {"loan": "5572"}, "type": "gold"},
+			{"type": "land"}
+		]
+	}`).(map[string]interface{})
+	join := &models.JoinSpec{Base: "loans", On: "loanId", Keys: map[string]string{"collateral": "ref.loan"}}
+
+	tree, unmatched, err := MergeDocuments(docs, join)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys match by their text, so 5571 joins "5571"; a base record without
+	// a key is left as it was
+	wantLoans := mustJSON(t, `[
+		{"loanId": 5571, "amount": 100,
+			"applicants": [{"loanId": "5571", "name": "Asha"}, {"loanId": 5571, "name": "Ravi"}],
+			"collateral": []},
+		{"loanId": "5572", "amount": 200,
+			"applicants": [],
+			"collateral": [{"ref": {"loan": "5572"}, "type": "gold"}]},
+		{"amount": 300}
+	]`)
+	if got := normalizeJSON(tree["loans"]); !reflect.DeepEqual(got, wantLoans) {
+		t.Errorf("loans = %v\nwant %v", got, wantLoans)
+	}
+	if !reflect.DeepEqual(tree["applicants"], docs["applicants"]) {
+		t.Errorf("applicants = %v, want the document unchanged", tree["applicants"])
+	}
+
+	// Unmatched records are sorted by document, index and missing document
+	wantUnmatched := []UnmatchedRecord{
+		{Document: "applicants", Index: 2, Key: 9999.0, Reason: "no matching loans record"},
+		{Document: "collateral", Index: 1, Reason: "missing join key"},
+		{Document: "loans", Index: 0, Key: 5571.0, MissingIn: "collateral", Reason: "no matching collateral record"},
+		{Document: "loans", Index: 1, Key: "5572", MissingIn: "applicants", Reason: "no matching applicants record"},
+		{Document: "loans", Index: 2, Reason: "missing join key"},
+	}
+	if !reflect.DeepEqual(unmatched, wantUnmatched) {
+		t.Errorf("unmatched =\n%+v\nwant\n%+v", unmatched, wantUnmatched)
+	}
+}
+
+func TestMergeDocumentsObjectBase(t *testing.T) {
+	docs := mustJSON(t, `{"loan": {"loanId": 1}, "bureau": [{"loanId": 1, "score": 700}, {"loanId": 2, "score": 650}]}`).(map[string]interface{})
+	tree, unmatched, err := MergeDocuments(docs, &models.JoinSpec{Base: "loan", On: "loanId"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustJSON(t, `{"loanId": 1, "bureau": [{"loanId": 1, "score": 700}]}`)
+	if got := normalizeJSON(tree["loan"]); !reflect.DeepEqual(got, want) {
+		t.Errorf("loan = %v, want %v", got, want)
+	}
+	if len(unmatched) != 1 || unmatched[0].Document != "bureau" || unmatched[0].Index != 1 {
+		t.Errorf("unmatched = %+v, want the second bureau record", unmatched)
+	}
+}
+
+func TestMergeDocumentsErrors(t *testing.T) {
+	docs := func(s string) map[string]interface{} { return mustJSON(t, s).(map[string]interface{}) }
+
+	if _, _, err := MergeDocuments(docs(`{"loans": []}`), &models.JoinSpec{Base: "missing", On: "loanId"}); err == nil {
+		t.Error("unknown base document accepted")
+	}
+	if _, _, err := MergeDocuments(docs(`{"loans": []}`), &models.JoinSpec{Base: "loans", On: "loanId", Keys: map[string]string{"other": "id"}}); err == nil {
+		t.Error("key for an unknown document accepted")
+	}
+	if _, _, err := MergeDocuments(docs(`{"loans": 1, "applicants": []}`), &models.JoinSpec{Base: "loans", On: "loanId"}); err == nil {
+		t.Error("scalar base document accepted")
+	}
+}
@@ -0,0 +1,346 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/antonmedv/expr"
+	"github.com/antonmedv/expr/vm"
+)
+
+// JSONPath is a compiled JSONPath source expression. It supports member
+// access (.name, ['name']), indexes including negative ones and [last],
+// wildcards (.* and [*]), recursive descent (..name, ..*) and filters such
+// as [?(@.addressSubType=='Permanent')]. Filters are expr expressions in
+// which @ is the element being tested.
+type JSONPath struct {
+	segments []pathSegment
+}
+
+type segmentKind int
+
+const (
+	segmentName segmentKind = iota
+	segmentIndex
+	segmentWildcard
+	segmentFilter
+)
+
+type pathSegment struct {
+	kind    segmentKind
+	descent bool
+	name    string
+	index   int
+	last    bool
+	filter  *vm.Program
+}
+
+// pathNode is a value reached by a JSONPath together with its concrete path.
+type pathNode struct {
+	value interface{}
+	path  []string
+}
+
+// CompileJSONPath parses a JSONPath expression. The leading '$' is optional.
+func CompileJSONPath(source string) (*JSONPath, error) {
+	p := &JSONPath{}
+	s := strings.TrimSpace(source)
+	s = strings.TrimPrefix(s, "$")
+	if s != "" && s[0] != '.' && s[0] != '[' {
+		s = "." + s
+	}
+
+	for len(s) > 0 {
+		descent := false
+		switch {
+		case strings.HasPrefix(s, ".."):
+			descent = true
+			s = s[2:]
+		case s[0] == '.':
+			s = s[1:]
+		case s[0] == '[':
+		default:
+			return nil, fmt.Errorf("jsonpath %q: unexpected %q", source, s[0])
+		}
+
+		var seg pathSegment
+		if strings.HasPrefix(s, "[") {
+			end := closingBracket(s)
+			if end < 0 {
+				return nil, fmt.Errorf("jsonpath %q: unterminated '['", source)
+			}
+			var err error
+			if seg, err = parseBracket(strings.TrimSpace(s[1:end])); err != nil {
+				return nil, fmt.Errorf("jsonpath %q: %s", source, err.Error())
+			}
+			s = s[end+1:]
+		} else {
+			end := strings.IndexAny(s, ".[")
+			if end < 0 {
+				end = len(s)
+			}
+			name := s[:end]
+			s = s[end:]
+			switch name {
+			case "":
+				return nil, fmt.Errorf("jsonpath %q: empty member name", source)
+			case "*":
+				seg = pathSegment{kind: segmentWildcard}
+			default:
+				seg = pathSegment{kind: segmentName, name: name}
+			}
+		}
+		seg.descent = descent
+		p.segments = append(p.segments, seg)
+	}
+	return p, nil
+}
+
+// closingBracket returns the index of the ']' closing the '[' at s[0],
+// skipping quoted strings and nested brackets.
+func closingBracket(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' {
+				i++
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '[':
+			depth++
+		case ch == ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func parseBracket(content string) (pathSegment, error) {
+	switch {
+	case content == "*":
+		return pathSegment{kind: segmentWildcard}, nil
+	case content == "last":
+		return pathSegment{kind: segmentIndex, last: true}, nil
+	case strings.HasPrefix(content, "?"):
+		filter := strings.TrimSpace(content[1:])
+		if strings.HasPrefix(filter, "(") && strings.HasSuffix(filter, ")") {
+			filter = filter[1 : len(filter)-1]
+		}
+		program, err := expr.Compile(replaceCurrentNode(filter))
+		if err != nil {
+			return pathSegment{}, fmt.Errorf("invalid filter %q: %s", filter, err.Error())
+		}
+		return pathSegment{kind: segmentFilter, filter: program}, nil
+	case len(content) >= 2 && (content[0] == '\'' || content[0] == '"') && content[len(content)-1] == content[0]:
+		return pathSegment{kind: segmentName, name: content[1 : len(content)-1]}, nil
+	}
+	idx, err := strconv.Atoi(content)
+	if err != nil {
+		return pathSegment{}, fmt.Errorf("invalid selector [%s]", content)
+	}
+	return pathSegment{kind: segmentIndex, index: idx}, nil
+}
+
+// replaceCurrentNode rewrites the @ of a filter to the current variable,
+// leaving string literals alone.
+func replaceCurrentNode(filter string) string {
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(filter); i++ {
+		ch := filter[i]
+		switch {
+		case quote != 0:
+			if ch == '\\' && i+1 < len(filter) {
+				b.WriteByte(ch)
+				i++
+				ch = filter[i]
+			} else if ch == quote {
+				quote = 0
+			}
+		case ch == '\'' || ch == '"':
+			quote = ch
+		case ch == '@':
+			b.WriteString("current")
+			continue
+		}
+		b.WriteByte(ch)
+	}
+	return b.String()
+}
+
+// StaticPrefix returns the keys leading up to the first selector that
+// depends on the data, such as a filter, wildcard, descent or [last].
+func (p *JSONPath) StaticPrefix() []string {
+	var keys []string
+	for _, seg := range p.segments {
+		if seg.descent {
+			break
+		}
+		switch {
+		case seg.kind == segmentName:
+			keys = append(keys, seg.name)
+		case seg.kind == segmentIndex && !seg.last && seg.index >= 0:
+			keys = append(keys, strconv.Itoa(seg.index))
+		default:
+			return keys
+		}
+	}
+	return keys
+}
+
+// Select returns every node the path matches in data, in document order.
+func (p *JSONPath) Select(data interface{}) []pathNode {
+	nodes := []pathNode{{value: data}}
+	for _, seg := range p.segments {
+		var next []pathNode
+		for _, node := range nodes {
+			if seg.descent {
+				for _, d := range descendants(node) {
+					next = append(next, seg.apply(d)...)
+				}
+			} else {
+				next = append(next, seg.apply(node)...)
+			}
+		}
+		nodes = next
+		if len(nodes) == 0 {
+			break
+		}
+	}
+	return nodes
+}
+
+// Lookup resolves the path against data. A single match yields its value and
+// several matches yield them as an array; no match reports false.
+func (p *JSONPath) Lookup(data interface{}) (interface{}, bool) {
+	nodes := p.Select(data)
+	switch len(nodes) {
+	case 0:
+		return nil, false
+	case 1:
+		return nodes[0].value, true
+	}
+	values := make([]interface{}, len(nodes))
+	for i, node := range nodes {
+		values[i] = node.value
+	}
+	return values, true
+}
+
+func (seg pathSegment) apply(node pathNode) []pathNode {
+	switch seg.kind {
+	case segmentName:
+		if m, ok := node.value.(map[string]interface{}); ok {
+			if v, exists := m[seg.name]; exists {
+				return []pathNode{{value: v, path: appendPath(node.path, seg.name)}}
+			}
+		}
+	case segmentIndex:
+		if arr, ok := node.value.([]interface{}); ok {
+			idx := seg.index
+			if seg.last {
+				idx = len(arr) - 1
+			} else if idx < 0 {
+				idx += len(arr)
+			}
+			if idx >= 0 && idx < len(arr) {
+				return []pathNode{{value: arr[idx], path: appendPath(node.path, strconv.Itoa(idx))}}
+			}
+		}
+	case segmentWildcard:
+		return children(node)
+	case segmentFilter:
+		var matched []pathNode
+		for _, child := range children(node) {
+			result, err := expr.Run(seg.filter, map[string]interface{}{"current": child.value})
+			if truthy, ok := result.(bool); err == nil && ok && truthy {
+				matched = append(matched, child)
+			}
+		}
+		return matched
+	}
+	return nil
+}
+
+func children(node pathNode) []pathNode {
+	var out []pathNode
+	switch v := node.value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			out = append(out, pathNode{value: v[key], path: appendPath(node.path, key)})
+		}
+	case []interface{}:
+		for i, elem := range v {
+			out = append(out, pathNode{value: elem, path: appendPath(node.path, strconv.Itoa(i))})
+		}
+	}
+	return out
+}
+
+// descendants returns node and everything below it, parents first.
+func descendants(node pathNode) []pathNode {
+	out := []pathNode{node}
+	for _, child := range children(node) {
+		out = append(out, descendants(child)...)
+	}
+	return out
+}
+
+// jsonPathCache holds compiled source expressions, since the same rules run
+// for every request.
+var jsonPathCache sync.Map
+
+func cachedJSONPath(source string) (*JSONPath, error) {
+	if p, ok := jsonPathCache.Load(source); ok {
+		return p.(*JSONPath), nil
+	}
+	p, err := CompileJSONPath(source)
+	if err != nil {
+		return nil, err
+	}
+	jsonPathCache.Store(source, p)
+	return p, nil
+}
+
+// ResolveSourcePath reads a rule's source path from input. Paths holding a
+// single JSONPath expression are evaluated as such; all others are plain
+// key lists.
+func ResolveSourcePath(input map[string]interface{}, path []string) (interface{}, bool) {
+	if !models.IsJSONPath(path) {
+		return GetNestedValue(input, path)
+	}
+	p, err := cachedJSONPath(path[0])
+	if err != nil {
+		return nil, false
+	}
+	return p.Lookup(input)
+}
+
+// sourcePathReads returns the concrete input paths a rule's source path reads
+// in input. A plain path reads itself; a JSONPath reads each node it matches.
+func sourcePathReads(input map[string]interface{}, path []string) [][]string {
+	if !models.IsJSONPath(path) {
+		return [][]string{path}
+	}
+	p, err := cachedJSONPath(path[0])
+	if err != nil {
+		return nil
+	}
+	var reads [][]string
+	for _, node := range p.Select(input) {
+		reads = append(reads, node.path)
+	}
+	return reads
+}
@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+const jsonPathDocument = `{
+	"loanId": "L1",
+	"applicantDetails": [
+		{"name": "Asha", "addresses": [
+			{"addressSubType": "Permanent", "city": "Pune"},
+			{"addressSubType": "Current", "city": "Mumbai"}
+		]},
+		{"name": "Ravi", "addresses": [
+			{"addressSubType": "Permanent", "city": "Delhi"}
+		]}
+	],
+	"odd key": {"a.b": 1}
+}`
+
+func TestCompileJSONPathLookup(t *testing.T) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal([]byte(jsonPathDocument), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		path   string
+		want   interface{}
+		exists bool
+	}{
+		{"member", "$.loanId", "L1", true},
+		{"without dollar", "loanId", "L1", true},
+		{"index", "$.applicantDetails[1].name", "Ravi", true},
+		{"negative index", "$.applicantDetails[-1].name", "Ravi", true},
+		{"last", "$.applicantDetails[last].name", "Ravi", true},
+		{"bracket name", "$['odd key']['a.b']", 1.0, true},
+		{"wildcard", "$.applicantDetails[*].name", []interface{}{"Asha", "Ravi"}, true},
+		{"dot wildcard", "$.applicantDetails.*.name", []interface{}{"Asha", "Ravi"}, true},
+		{"descent", "$..city", []interface{}{"Pune", "Mumbai", "Delhi"}, true},
+		{
+			"filter",
+			"$.applicantDetails[0].addresses[?(@.addressSubType=='Permanent')].city",
+			"Pune", true,
+		},
+		{
+			"filter across elements",
+			"$.applicantDetails[*].addresses[?(@.addressSubType=='Permanent')].city",
+			[]interface{}{"Pune", "Delhi"}, true,
+		},
+		{"filter with @ in literal", "$.applicantDetails[?(@.name=='@')]", nil, false},
+		{"missing member", "$.missing", nil, false},
+		{"index out of range", "$.applicantDetails[5]", nil, false},
+		{"index on object", "$.loanId[0]", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := CompileJSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("CompileJSONPath(%q): %v", tt.path, err)
+			}
+			got, exists := p.Lookup(doc)
+			if exists != tt.exists || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Lookup = %#v, %v; want %#v, %v", got, exists, tt.want, tt.exists)
+			}
+		})
+	}
+}
+
+func TestCompileJSONPathErrors(t *testing.T) {
+	tests := []string{
+		"$.applicantDetails[0",
+		"$.applicantDetails[abc]",
+		"$.applicantDetails[?(@.name ==)]",
+		"$.a..",
+		"$.a.",
+	}
+	for _, path := range tests {
+		t.Run(path, func(t *testing.T) {
+			if _, err := CompileJSONPath(path); err == nil {
+				t.Errorf("CompileJSONPath(%q) succeeded, want an error", path)
+			}
+		})
+	}
+}
+
+func TestJSONPathStaticPrefix(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"$.applicantDetails[0].name", []string{"applicantDetails", "0", "name"}},
+		{"$.applicantDetails[*].name", []string{"applicantDetails"}},
+		{"$.applicantDetails[last].name", []string{"applicantDetails"}},
+		{"$.applicantDetails[-1].name", []string{"applicantDetails"}},
+		{"$.applicantDetails[?(@.name=='Asha')]", []string{"applicantDetails"}},
+		{"$.loan..city", []string{"loan"}},
+		{"$..city", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			p, err := CompileJSONPath(tt.path)
+			if err != nil {
+				t.Fatalf("CompileJSONPath(%q): %v", tt.path, err)
+			}
+			if got := p.StaticPrefix(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StaticPrefix = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransformWithJSONPathSource(t *testing.T) {
+	input := mustJSON(t, jsonPathDocument).(map[string]interface{})
+	rules := []models.MappingRule{
+		{
+			SourcePath:      []string{"$.applicantDetails[0].addresses[?(@.addressSubType=='Permanent')].city"},
+			DestinationPath: []string{"permanentCity"},
+			TransformType:   "copy",
+		},
+		{
+			SourcePath:      []string{"$.applicantDetails[*].name"},
+			DestinationPath: []string{"names"},
+			TransformType:   "copy",
+		},
+		{
+			SourcePath:      []string{"applicantDetails", "1", "name"},
+			DestinationPath: []string{"coApplicant"},
+			TransformType:   "copy",
+		},
+	}
+
+	output, err := Transform(input, rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"permanentCity": "Pune",
+		"names":         []interface{}{"Asha", "Ravi"},
+		"coApplicant":   "Ravi",
+	}
+	if !reflect.DeepEqual(output, want) {
+		t.Errorf("output = %v, want %v", output, want)
+	}
+}
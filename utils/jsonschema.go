@@ -0,0 +1,616 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSchemaRefDepth bounds $ref chains so that recursive schemas applied to
+// self-similar data, or refs that point at themselves, cannot loop forever.
+const maxSchemaRefDepth = 64
+
+// SchemaError is a single JSON Schema validation failure. InstanceLocation is
+// a JSON pointer into the validated document and KeywordLocation a JSON
+// pointer into the schema naming the keyword that failed.
+type SchemaError struct {
+	InstanceLocation string `json:"instance_location"`
+	KeywordLocation  string `json:"keyword_location"`
+	Message          string `json:"message"`
+}
+
+// SchemaSet validates documents against JSON Schema draft 2020-12 schemas held
+// in memory. Schemas are registered under a name and, when they declare one,
+// their $id; a $ref naming another schema in the set is resolved locally and
+// never fetched over the network. Format is treated as an annotation and
+// unevaluatedProperties/unevaluatedItems are not supported.
+type SchemaSet struct {
+	docs     map[string]interface{}
+	patterns map[string]*regexp.Regexp
+}
+
+// NewSchemaSet returns an empty schema set.
+func NewSchemaSet() *SchemaSet {
+	return &SchemaSet{
+		docs:     make(map[string]interface{}),
+		patterns: make(map[string]*regexp.Regexp),
+	}
+}
+
+// Add registers a schema document under name and under its $id, if any.
+func (s *SchemaSet) Add(name string, document interface{}) {
+	doc := normalizeJSON(document)
+	s.docs[name] = doc
+	if id := SchemaID(doc); id != "" {
+		s.docs[id] = doc
+	}
+}
+
+// SchemaID returns the $id a schema document declares, without a trailing
+// empty fragment, or "" when it declares none.
+func SchemaID(document interface{}) string {
+	if m, ok := document.(map[string]interface{}); ok {
+		if id, ok := m["$id"].(string); ok {
+			return strings.TrimSuffix(id, "#")
+		}
+	}
+	return ""
+}
+
+// SchemaRefs lists the other schemas a schema document's $refs name, by the
+// part of each ref before its fragment, in sorted order.
+func SchemaRefs(document interface{}) []string {
+	seen := make(map[string]bool)
+	var walk func(node interface{})
+	walk = func(node interface{}) {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := n["$ref"].(string); ok {
+				if base := strings.SplitN(ref, "#", 2)[0]; base != "" {
+					seen[base] = true
+				}
+			}
+			for key, child := range n {
+				if key != "enum" && key != "const" {
+					walk(child)
+				}
+			}
+		case []interface{}:
+			for _, elem := range n {
+				walk(elem)
+			}
+		}
+	}
+	walk(normalizeJSON(document))
+
+	refs := make([]string, 0, len(seen))
+	for ref := range seen {
+		refs = append(refs, ref)
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// Validate validates instance against the schema registered as name. The
+// error is set when the schema itself cannot be used, for example because a
+// $ref does not resolve.
+func (s *SchemaSet) Validate(name string, instance interface{}) ([]SchemaError, error) {
+	doc, ok := s.docs[name]
+	if !ok {
+		return nil, fmt.Errorf("schema %q not found", name)
+	}
+	v := &schemaValidator{set: s}
+	errs := v.check(doc, doc, normalizeJSON(instance), "", "", 0)
+	if v.err != nil {
+		return nil, v.err
+	}
+	if errs == nil {
+		errs = []SchemaError{}
+	}
+	return errs, nil
+}
+
+// CheckSchema reports whether document is usable as a schema within the set:
+// it must be an object or a boolean, every pattern must compile and every
+// $ref must resolve.
+func (s *SchemaSet) CheckSchema(document interface{}) error {
+	doc := normalizeJSON(document)
+	switch doc.(type) {
+	case map[string]interface{}, bool:
+	default:
+		return fmt.Errorf("a schema must be a JSON object or boolean")
+	}
+	var walk func(node interface{}) error
+	walk = func(node interface{}) error {
+		switch n := node.(type) {
+		case map[string]interface{}:
+			if ref, ok := n["$ref"].(string); ok {
+				if _, _, err := s.resolveRef(doc, ref); err != nil {
+					return err
+				}
+			}
+			if pattern, ok := n["pattern"].(string); ok {
+				if _, err := s.pattern(pattern); err != nil {
+					return err
+				}
+			}
+			for _, key := range sortedKeys(n) {
+				if key == "enum" || key == "const" {
+					continue
+				}
+				if err := walk(n[key]); err != nil {
+					return err
+				}
+			}
+		case []interface{}:
+			for _, elem := range n {
+				if err := walk(elem); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk(doc)
+}
+
+func (s *SchemaSet) pattern(expr string) (*regexp.Regexp, error) {
+	if re, ok := s.patterns[expr]; ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %s", expr, err.Error())
+	}
+	s.patterns[expr] = re
+	return re, nil
+}
+
+// resolveRef resolves a $ref against the document it appears in. Refs
+// starting with '#' stay within that document; any other ref names a schema
+// in the set, optionally followed by a '#' fragment. Fragments are either a
+// JSON pointer or an $anchor name.
+func (s *SchemaSet) resolveRef(doc interface{}, ref string) (interface{}, interface{}, error) {
+	base, fragment := ref, ""
+	if i := strings.Index(ref, "#"); i >= 0 {
+		base, fragment = ref[:i], ref[i+1:]
+	}
+	if base != "" {
+		target, ok := s.docs[base]
+		if !ok {
+			return nil, nil, fmt.Errorf("$ref %q: schema %q not found", ref, base)
+		}
+		doc = target
+	}
+
+	if fragment == "" {
+		return doc, doc, nil
+	}
+	if strings.HasPrefix(fragment, "/") {
+		node, ok := resolvePointer(doc, fragment)
+		if !ok {
+			return nil, nil, fmt.Errorf("$ref %q does not resolve", ref)
+		}
+		return node, doc, nil
+	}
+	if node := findAnchor(doc, fragment); node != nil {
+		return node, doc, nil
+	}
+	return nil, nil, fmt.Errorf("$ref %q: anchor %q not found", ref, fragment)
+}
+
+func resolvePointer(doc interface{}, pointer string) (interface{}, bool) {
+	current := doc
+	for _, token := range strings.Split(pointer, "/")[1:] {
+		token = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[token]
+			if !ok {
+				return nil, false
+			}
+			current = next
+		case []interface{}:
+			idx, err := strconv.Atoi(token)
+			if err != nil || idx < 0 || idx >= len(v) {
+				return nil, false
+			}
+			current = v[idx]
+		default:
+			return nil, false
+		}
+	}
+	return current, true
+}
+
+func findAnchor(node interface{}, anchor string) interface{} {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if a, ok := n["$anchor"].(string); ok && a == anchor {
+			return n
+		}
+		for _, key := range sortedKeys(n) {
+			if key == "enum" || key == "const" {
+				continue
+			}
+			if found := findAnchor(n[key], anchor); found != nil {
+				return found
+			}
+		}
+	case []interface{}:
+		for _, elem := range n {
+			if found := findAnchor(elem, anchor); found != nil {
+				return found
+			}
+		}
+	}
+	return nil
+}
+
+// JSONPointer builds a JSON pointer from path tokens.
+func JSONPointer(tokens ...string) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		b.WriteString(strings.ReplaceAll(strings.ReplaceAll(token, "~", "~0"), "/", "~1"))
+	}
+	return b.String()
+}
+
+type schemaValidator struct {
+	set *SchemaSet
+	err error
+}
+
+// check validates instance against schema and returns every failure. doc is
+// the document schema belongs to, used to resolve local refs; ptr and kw are
+// the instance and keyword locations reached so far.
+func (v *schemaValidator) check(schema, doc, instance interface{}, ptr, kw string, depth int) []SchemaError {
+	if v.err != nil {
+		return nil
+	}
+	fail := func(keyword, format string, args ...interface{}) SchemaError {
+		return SchemaError{
+			InstanceLocation: ptr,
+			KeywordLocation:  kw + JSONPointer(keyword),
+			Message:          fmt.Sprintf(format, args...),
+		}
+	}
+
+	switch sch := schema.(type) {
+	case bool:
+		if !sch {
+			return []SchemaError{{InstanceLocation: ptr, KeywordLocation: kw, Message: "no value is allowed here"}}
+		}
+		return nil
+	case map[string]interface{}:
+		var errs []SchemaError
+
+		if ref, ok := sch["$ref"].(string); ok {
+			if depth >= maxSchemaRefDepth {
+				v.err = fmt.Errorf("$ref %q nests deeper than %d levels", ref, maxSchemaRefDepth)
+				return nil
+			}
+			target, targetDoc, err := v.set.resolveRef(doc, ref)
+			if err != nil {
+				v.err = err
+				return nil
+			}
+			errs = append(errs, v.check(target, targetDoc, instance, ptr, kw+JSONPointer("$ref"), depth+1)...)
+		}
+
+		errs = append(errs, v.checkGeneric(sch, instance, fail)...)
+		errs = append(errs, v.checkApplicators(sch, doc, instance, ptr, kw, depth, fail)...)
+
+		switch inst := instance.(type) {
+		case float64:
+			errs = append(errs, checkNumber(sch, inst, fail)...)
+		case string:
+			errs = append(errs, v.checkString(sch, inst, fail)...)
+		case []interface{}:
+			errs = append(errs, v.checkArray(sch, doc, inst, ptr, kw, depth, fail)...)
+		case map[string]interface{}:
+			errs = append(errs, v.checkObject(sch, doc, inst, ptr, kw, depth, fail)...)
+		}
+		return errs
+	default:
+		v.err = fmt.Errorf("schema at %s is not an object or boolean", displayPointer(kw))
+		return nil
+	}
+}
+
+type failFunc func(keyword, format string, args ...interface{}) SchemaError
+
+func (v *schemaValidator) checkGeneric(sch map[string]interface{}, instance interface{}, fail failFunc) []SchemaError {
+	var errs []SchemaError
+	if t, ok := sch["type"]; ok {
+		actual := JSONTypeOf(instance)
+		var allowed []string
+		switch tv := t.(type) {
+		case string:
+			allowed = []string{tv}
+		case []interface{}:
+			for _, elem := range tv {
+				if s, ok := elem.(string); ok {
+					allowed = append(allowed, s)
+				}
+			}
+		}
+		matched := false
+		for _, a := range allowed {
+			if a == actual || (a == "number" && actual == "integer") {
+				matched = true
+			}
+		}
+		if !matched {
+			errs = append(errs, fail("type", "expected %s, got %s", strings.Join(allowed, " or "), actual))
+		}
+	}
+	if enum, ok := sch["enum"].([]interface{}); ok {
+		found := false
+		for _, option := range enum {
+			if reflect.DeepEqual(option, instance) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			errs = append(errs, fail("enum", "value is not one of the allowed values"))
+		}
+	}
+	if constant, ok := sch["const"]; ok && !reflect.DeepEqual(constant, instance) {
+		errs = append(errs, fail("const", "value does not equal the constant"))
+	}
+	return errs
+}
+
+func (v *schemaValidator) checkApplicators(sch map[string]interface{}, doc, instance interface{}, ptr, kw string, depth int, fail failFunc) []SchemaError {
+	var errs []SchemaError
+	if all, ok := sch["allOf"].([]interface{}); ok {
+		for i, sub := range all {
+			errs = append(errs, v.check(sub, doc, instance, ptr, kw+JSONPointer("allOf", strconv.Itoa(i)), depth)...)
+		}
+	}
+	if anyOf, ok := sch["anyOf"].([]interface{}); ok {
+		matched := false
+		for i, sub := range anyOf {
+			if len(v.check(sub, doc, instance, ptr, kw+JSONPointer("anyOf", strconv.Itoa(i)), depth)) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			errs = append(errs, fail("anyOf", "value does not match any of the anyOf schemas"))
+		}
+	}
+	if one, ok := sch["oneOf"].([]interface{}); ok {
+		matches := 0
+		for i, sub := range one {
+			if len(v.check(sub, doc, instance, ptr, kw+JSONPointer("oneOf", strconv.Itoa(i)), depth)) == 0 {
+				matches++
+			}
+		}
+		if matches != 1 {
+			errs = append(errs, fail("oneOf", "value matches %d of the oneOf schemas, expected exactly 1", matches))
+		}
+	}
+	if not, ok := sch["not"]; ok {
+		if len(v.check(not, doc, instance, ptr, kw+JSONPointer("not"), depth)) == 0 {
+			errs = append(errs, fail("not", "value must not match the schema"))
+		}
+	}
+	if cond, ok := sch["if"]; ok {
+		if len(v.check(cond, doc, instance, ptr, kw+JSONPointer("if"), depth)) == 0 {
+			if then, ok := sch["then"]; ok {
+				errs = append(errs, v.check(then, doc, instance, ptr, kw+JSONPointer("then"), depth)...)
+			}
+		} else if otherwise, ok := sch["else"]; ok {
+			errs = append(errs, v.check(otherwise, doc, instance, ptr, kw+JSONPointer("else"), depth)...)
+		}
+	}
+	return errs
+}
+
+func checkNumber(sch map[string]interface{}, n float64, fail failFunc) []SchemaError {
+	var errs []SchemaError
+	if limit, ok := sch["minimum"].(float64); ok && n < limit {
+		errs = append(errs, fail("minimum", "value must be >= %s", formatNumber(limit)))
+	}
+	if limit, ok := sch["maximum"].(float64); ok && n > limit {
+		errs = append(errs, fail("maximum", "value must be <= %s", formatNumber(limit)))
+	}
+	if limit, ok := sch["exclusiveMinimum"].(float64); ok && n <= limit {
+		errs = append(errs, fail("exclusiveMinimum", "value must be > %s", formatNumber(limit)))
+	}
+	if limit, ok := sch["exclusiveMaximum"].(float64); ok && n >= limit {
+		errs = append(errs, fail("exclusiveMaximum", "value must be < %s", formatNumber(limit)))
+	}
+	if divisor, ok := sch["multipleOf"].(float64); ok && divisor > 0 {
+		if q := n / divisor; math.Abs(q-math.Round(q)) > 1e-9 {
+			errs = append(errs, fail("multipleOf", "value must be a multiple of %s", formatNumber(divisor)))
+		}
+	}
+	return errs
+}
+
+func (v *schemaValidator) checkString(sch map[string]interface{}, s string, fail failFunc) []SchemaError {
+	var errs []SchemaError
+	length := utf8.RuneCountInString(s)
+	if limit, ok := schemaInt(sch, "minLength"); ok && length < limit {
+		errs = append(errs, fail("minLength", "length must be >= %d", limit))
+	}
+	if limit, ok := schemaInt(sch, "maxLength"); ok && length > limit {
+		errs = append(errs, fail("maxLength", "length must be <= %d", limit))
+	}
+	if expr, ok := sch["pattern"].(string); ok {
+		re, err := v.set.pattern(expr)
+		if err != nil {
+			v.err = err
+			return nil
+		}
+		if !re.MatchString(s) {
+			errs = append(errs, fail("pattern", "value does not match %s", expr))
+		}
+	}
+	return errs
+}
+
+func (v *schemaValidator) checkArray(sch map[string]interface{}, doc interface{}, arr []interface{}, ptr, kw string, depth int, fail failFunc) []SchemaError {
+	var errs []SchemaError
+	if limit, ok := schemaInt(sch, "minItems"); ok && len(arr) < limit {
+		errs = append(errs, fail("minItems", "array must have at least %d items", limit))
+	}
+	if limit, ok := schemaInt(sch, "maxItems"); ok && len(arr) > limit {
+		errs = append(errs, fail("maxItems", "array must have at most %d items", limit))
+	}
+	if unique, ok := sch["uniqueItems"].(bool); ok && unique {
+	dup:
+		for i := range arr {
+			for j := 0; j < i; j++ {
+				if reflect.DeepEqual(arr[i], arr[j]) {
+					errs = append(errs, fail("uniqueItems", "items %d and %d are equal", j, i))
+					break dup
+				}
+			}
+		}
+	}
+
+	prefix, _ := sch["prefixItems"].([]interface{})
+	for i, sub := range prefix {
+		if i >= len(arr) {
+			break
+		}
+		errs = append(errs, v.check(sub, doc, arr[i], ptr+JSONPointer(strconv.Itoa(i)), kw+JSONPointer("prefixItems", strconv.Itoa(i)), depth)...)
+	}
+	if items, ok := sch["items"]; ok {
+		for i := len(prefix); i < len(arr); i++ {
+			errs = append(errs, v.check(items, doc, arr[i], ptr+JSONPointer(strconv.Itoa(i)), kw+JSONPointer("items"), depth)...)
+		}
+	}
+
+	if contains, ok := sch["contains"]; ok {
+		count := 0
+		for i, elem := range arr {
+			if len(v.check(contains, doc, elem, ptr+JSONPointer(strconv.Itoa(i)), kw+JSONPointer("contains"), depth)) == 0 {
+				count++
+			}
+		}
+		minContains := 1
+		if limit, ok := schemaInt(sch, "minContains"); ok {
+			minContains = limit
+		}
+		if count < minContains {
+			errs = append(errs, fail("contains", "array must contain at least %d matching items, found %d", minContains, count))
+		}
+		if limit, ok := schemaInt(sch, "maxContains"); ok && count > limit {
+			errs = append(errs, fail("maxContains", "array must contain at most %d matching items, found %d", limit, count))
+		}
+	}
+	return errs
+}
+
+func (v *schemaValidator) checkObject(sch map[string]interface{}, doc interface{}, obj map[string]interface{}, ptr, kw string, depth int, fail failFunc) []SchemaError {
+	var errs []SchemaError
+	if limit, ok := schemaInt(sch, "minProperties"); ok && len(obj) < limit {
+		errs = append(errs, fail("minProperties", "object must have at least %d properties", limit))
+	}
+	if limit, ok := schemaInt(sch, "maxProperties"); ok && len(obj) > limit {
+		errs = append(errs, fail("maxProperties", "object must have at most %d properties", limit))
+	}
+	if required, ok := sch["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, exists := obj[key]; !exists {
+					errs = append(errs, SchemaError{
+						InstanceLocation: ptr + JSONPointer(key),
+						KeywordLocation:  kw + JSONPointer("required"),
+						Message:          fmt.Sprintf("required property %q is missing", key),
+					})
+				}
+			}
+		}
+	}
+	if deps, ok := sch["dependentRequired"].(map[string]interface{}); ok {
+		for _, trigger := range sortedKeys(deps) {
+			if _, present := obj[trigger]; !present {
+				continue
+			}
+			names, _ := deps[trigger].([]interface{})
+			for _, name := range names {
+				if key, ok := name.(string); ok {
+					if _, exists := obj[key]; !exists {
+						errs = append(errs, fail("dependentRequired", "property %q is required when %q is present", key, trigger))
+					}
+				}
+			}
+		}
+	}
+	if deps, ok := sch["dependentSchemas"].(map[string]interface{}); ok {
+		for _, trigger := range sortedKeys(deps) {
+			if _, present := obj[trigger]; present {
+				errs = append(errs, v.check(deps[trigger], doc, obj, ptr, kw+JSONPointer("dependentSchemas", trigger), depth)...)
+			}
+		}
+	}
+	if names, ok := sch["propertyNames"]; ok {
+		for _, key := range sortedKeys(obj) {
+			for _, e := range v.check(names, doc, key, ptr+JSONPointer(key), kw+JSONPointer("propertyNames"), depth) {
+				e.Message = fmt.Sprintf("property name %q: %s", key, e.Message)
+				errs = append(errs, e)
+			}
+		}
+	}
+
+	properties, _ := sch["properties"].(map[string]interface{})
+	patterns, _ := sch["patternProperties"].(map[string]interface{})
+	additional, hasAdditional := sch["additionalProperties"]
+	for _, key := range sortedKeys(obj) {
+		value := obj[key]
+		childPtr := ptr + JSONPointer(key)
+		matched := false
+		if sub, ok := properties[key]; ok {
+			matched = true
+			errs = append(errs, v.check(sub, doc, value, childPtr, kw+JSONPointer("properties", key), depth)...)
+		}
+		for _, expr := range sortedKeys(patterns) {
+			re, err := v.set.pattern(expr)
+			if err != nil {
+				v.err = err
+				return nil
+			}
+			if re.MatchString(key) {
+				matched = true
+				errs = append(errs, v.check(patterns[expr], doc, value, childPtr, kw+JSONPointer("patternProperties", expr), depth)...)
+			}
+		}
+		if !matched && hasAdditional {
+			errs = append(errs, v.check(additional, doc, value, childPtr, kw+JSONPointer("additionalProperties"), depth)...)
+		}
+	}
+	return errs
+}
+
+// schemaInt reads a non-negative integer keyword.
+func schemaInt(sch map[string]interface{}, keyword string) (int, bool) {
+	n, ok := sch[keyword].(float64)
+	if !ok || n < 0 {
+		return 0, false
+	}
+	return int(n), true
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
+
+func displayPointer(pointer string) string {
+	if pointer == "" {
+		return "#"
+	}
+	return "#" + pointer
+}
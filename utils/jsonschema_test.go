@@ -0,0 +1,212 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSchemaSetValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   string
+		instance string
+		// want lists the instance and keyword location of each error
+		want [][2]string
+	}{
+		{
+			name:     "valid object",
+			schema:   `{"type": "object", "required": ["loanId"], "properties": {"loanId": {"type": "string"}}}`,
+			instance: `{"loanId": "L1"}`,
+		},
+		{
+			name:     "type mismatch",
+			schema:   `{"properties": {"amount": {"type": "number"}}}`,
+			instance: `{"amount": "100"}`,
+			want:     [][2]string{{"/amount", "/properties/amount/type"}},
+		},
+		{
+			name:     "integer is a number",
+			schema:   `{"type": "number"}`,
+			instance: `5`,
+		},
+		{
+			name:     "number is not an integer",
+			schema:   `{"type": "integer"}`,
+			instance: `5.5`,
+			want:     [][2]string{{"", "/type"}},
+		},
+		{
+			name:     "type list",
+			schema:   `{"type": ["string", "null"]}`,
+			instance: `null`,
+		},
+		{
+			name:     "missing required",
+			schema:   `{"required": ["loanId", "amount"]}`,
+			instance: `{"amount": 1}`,
+			want:     [][2]string{{"/loanId", "/required"}},
+		},
+		{
+			name:     "enum and const",
+			schema:   `{"properties": {"a": {"enum": ["x", "y"]}, "b": {"const": 1}}}`,
+			instance: `{"a": "z", "b": 1}`,
+			want:     [][2]string{{"/a", "/properties/a/enum"}},
+		},
+		{
+			name:     "numeric bounds",
+			schema:   `{"items": {"minimum": 0, "exclusiveMaximum": 10, "multipleOf": 2}}`,
+			instance: `[-2, 10, 3]`,
+			want: [][2]string{
+				{"/0", "/items/minimum"},
+				{"/1", "/items/exclusiveMaximum"},
+				{"/2", "/items/multipleOf"},
+			},
+		},
+		{
+			name:     "string length counts runes",
+			schema:   `{"maxLength": 2, "pattern": "^[a-zé]+$"}`,
+			instance: `"éé"`,
+		},
+		{
+			name:     "pattern",
+			schema:   `{"pattern": "^[0-9]{6}$"}`,
+			instance: `"41100"`,
+			want:     [][2]string{{"", "/pattern"}},
+		},
+		{
+			name:     "additional properties",
+			schema:   `{"properties": {"a": {}}, "patternProperties": {"^x-": {}}, "additionalProperties": false}`,
+			instance: `{"a": 1, "x-trace": 2, "b": 3}`,
+			want:     [][2]string{{"/b", "/additionalProperties"}},
+		},
+		{
+			name:     "prefix items and unique items",
+			schema:   `{"prefixItems": [{"type": "string"}], "items": {"type": "number"}, "uniqueItems": true}`,
+			instance: `["a", 1, 1]`,
+			want:     [][2]string{{"", "/uniqueItems"}},
+		},
+		{
+			name:     "contains",
+			schema:   `{"contains": {"const": "Permanent"}, "minContains": 2}`,
+			instance: `["Permanent", "Current"]`,
+			want:     [][2]string{{"", "/contains"}},
+		},
+		{
+			name:     "oneOf matching twice",
+			schema:   `{"oneOf": [{"type": "number"}, {"minimum": 0}]}`,
+			instance: `1`,
+			want:     [][2]string{{"", "/oneOf"}},
+		},
+		{
+			name:     "anyOf and not",
+			schema:   `{"anyOf": [{"type": "string"}, {"type": "null"}], "not": {"const": ""}}`,
+			instance: `""`,
+			want:     [][2]string{{"", "/not"}},
+		},
+		{
+			name:     "if then else",
+			schema:   `{"if": {"properties": {"kind": {"const": "secured"}}}, "then": {"required": ["collateral"]}, "else": {"required": ["reason"]}}`,
+			instance: `{"kind": "secured"}`,
+			want:     [][2]string{{"/collateral", "/then/required"}},
+		},
+		{
+			name:     "dependent required",
+			schema:   `{"dependentRequired": {"coApplicant": ["coApplicantPan"]}}`,
+			instance: `{"coApplicant": "Ravi"}`,
+			want:     [][2]string{{"", "/dependentRequired"}},
+		},
+		{
+			name:     "local ref",
+			schema:   `{"$defs": {"pin": {"type": "string", "minLength": 6}}, "properties": {"pin": {"$ref": "#/$defs/pin"}}}`,
+			instance: `{"pin": "411"}`,
+			want:     [][2]string{{"/pin", "/properties/pin/$ref/minLength"}},
+		},
+		{
+			name:     "anchor ref",
+			schema:   `{"$defs": {"pin": {"$anchor": "pin", "type": "string"}}, "items": {"$ref": "#pin"}}`,
+			instance: `["411001", 411001]`,
+			want:     [][2]string{{"/1", "/items/$ref/type"}},
+		},
+		{
+			name:     "ref to another schema",
+			schema:   `{"properties": {"address": {"$ref": "address"}}}`,
+			instance: `{"address": {"city": "Pune"}}`,
+			want:     [][2]string{{"/address/pin", "/properties/address/$ref/required"}},
+		},
+		{
+			name:     "ref by id",
+			schema:   `{"$ref": "https://example.com/address.json#/properties/pin"}`,
+			instance: `411001`,
+			want:     [][2]string{{"", "/$ref/type"}},
+		},
+		{
+			name:     "recursive ref",
+			schema:   `{"properties": {"children": {"items": {"$ref": "#"}}, "name": {"type": "string"}}}`,
+			instance: `{"children": [{"children": [{"name": 1}]}]}`,
+			want:     [][2]string{{"/children/0/children/0/name", "/properties/children/items/$ref/properties/children/items/$ref/properties/name/type"}},
+		},
+		{
+			name:     "false schema",
+			schema:   `{"properties": {"legacy": false}}`,
+			instance: `{"legacy": 1}`,
+			want:     [][2]string{{"/legacy", "/properties/legacy"}},
+		},
+		{
+			name:     "escaped pointer tokens",
+			schema:   `{"properties": {"a/b": {"type": "string"}}}`,
+			instance: `{"a/b": 1}`,
+			want:     [][2]string{{"/a~1b", "/properties/a~1b/type"}},
+		},
+	}
+
+	address := `{"$id": "https://example.com/address.json", "type": "object", "required": ["pin"], "properties": {"pin": {"type": "string"}}}`
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := NewSchemaSet()
+			set.Add("address", mustJSON(t, address))
+			set.Add("test", mustJSON(t, tt.schema))
+
+			errs, err := set.Validate("test", mustJSON(t, tt.instance))
+			if err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			got := make([][2]string, len(errs))
+			for i, e := range errs {
+				got[i] = [2]string{e.InstanceLocation, e.KeywordLocation}
+			}
+			want := tt.want
+			if want == nil {
+				want = [][2]string{}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("errors = %v, want %v\n%+v", got, want, errs)
+			}
+		})
+	}
+}
+
+func TestSchemaSetValidateUnusableSchema(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"unknown schema ref", `{"$ref": "missing"}`},
+		{"unresolved pointer", `{"$ref": "#/$defs/missing"}`},
+		{"unknown anchor", `{"$ref": "#nowhere"}`},
+		{"ref loop", `{"$defs": {"a": {"$ref": "#/$defs/a"}}, "$ref": "#/$defs/a"}`},
+		{"subschema of the wrong type", `{"properties": {"a": 1}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			set := NewSchemaSet()
+			set.Add("test", mustJSON(t, tt.schema))
+			if _, err := set.Validate("test", mustJSON(t, `{"a": 1}`)); err == nil {
+				t.Error("Validate succeeded, want an error")
+			}
+		})
+	}
+
+	if _, err := NewSchemaSet().Validate("missing", nil); err == nil {
+		t.Error("Validate of an unregistered schema succeeded, want an error")
+	}
+}
@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// MappingExport is the portable document produced by the mapping export
+// endpoint. Rules keep every definition field and their order; database IDs
+// and timestamps are left out so the file can be imported anywhere.
+type MappingExport struct {
+	Client     string                   `json:"client" yaml:"client"`
+	Version    int                      `json:"version" yaml:"version"`
+	Draft      bool                     `json:"draft" yaml:"draft"`
+	ExportedAt time.Time                `json:"exported_at" yaml:"exported_at"`
+	Rules      []map[string]interface{} `json:"rules" yaml:"rules"`
+}
+
+// csvLeadingColumns fixes the position of the core rule columns in CSV exports.
+var csvLeadingColumns = []string{"source_path", "destination_path", "transform_type", "transform_logic", "default_value", "required"}
+
+// NewMappingExport builds an export document for rules in their current order.
+// Sub-mappings are exported by name, without the rules a published version
+// snapshotted for them.
+func NewMappingExport(client string, version int, draft bool, rules []models.MappingRule) MappingExport {
+	rules = CollapseSubMappings(rules)
+	export := MappingExport{
+		Client:     client,
+		Version:    version,
+		Draft:      draft,
+		ExportedAt: time.Now().UTC(),
+		Rules:      make([]map[string]interface{}, len(rules)),
+	}
+	for i, rule := range rules {
+		export.Rules[i] = ruleFields(rule)
+	}
+	return export
+}
+
+// CollapseSubMappings returns a copy of rules in which subMapping rules, also
+// those nested in sub-rules, no longer carry the SubRules they were expanded
+// with, leaving the rules as they are stored in a draft.
+func CollapseSubMappings(rules []models.MappingRule) []models.MappingRule {
+	if rules == nil {
+		return nil
+	}
+	collapsed := make([]models.MappingRule, len(rules))
+	for i, rule := range rules {
+		if rule.TransformType == "subMapping" {
+			rule.SubRules = nil
+		} else if len(rule.SubRules) > 0 {
+			rule.SubRules = CollapseSubMappings(rule.SubRules)
+		}
+		collapsed[i] = rule
+	}
+	return collapsed
+}
+
+// WriteMappingExport encodes an export as json, yaml or csv. CSV exports carry
+// the metadata in leading '#' comment lines and use dotted paths unless a key
+// contains a dot, in which case the path is written as a JSON array.
+func WriteMappingExport(w io.Writer, export MappingExport, format string) error {
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(export)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(export); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "csv":
+		return writeMappingExportCSV(w, export)
+	default:
+		return fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+func writeMappingExportCSV(w io.Writer, export MappingExport) error {
+	fmt.Fprintf(w, "# client: %s\n", strings.ReplaceAll(export.Client, "\n", " "))
+	fmt.Fprintf(w, "# version: %d\n", export.Version)
+	fmt.Fprintf(w, "# draft: %t\n", export.Draft)
+	fmt.Fprintf(w, "# exported_at: %s\n", export.ExportedAt.Format(time.RFC3339))
+
+	columns := append([]string{}, csvLeadingColumns...)
+	leading := make(map[string]bool, len(columns))
+	for _, col := range columns {
+		leading[col] = true
+	}
+	var extra []string
+	seen := make(map[string]bool)
+	for _, rule := range export.Rules {
+		for key := range rule {
+			if !leading[key] && !seen[key] {
+				seen[key] = true
+				extra = append(extra, key)
+			}
+		}
+	}
+	sort.Strings(extra)
+	columns = append(columns, extra...)
+
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, rule := range export.Rules {
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			record[i] = exportCell(col, rule[col])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportCell(column string, value interface{}) string {
+	if keys, ok := value.([]interface{}); ok && (column == "source_path" || column == "destination_path") {
+		parts := make([]string, len(keys))
+		dotted := true
+		for i, key := range keys {
+			parts[i] = FormatScalar(key)
+			if strings.Contains(parts[i], ".") {
+				dotted = false
+			}
+		}
+		if dotted {
+			return strings.Join(parts, ".")
+		}
+	}
+	return FormatScalar(value)
+}
+
+// YAMLToJSON converts a YAML document to JSON so it can be decoded like any
+// other import body.
+func YAMLToJSON(data []byte) ([]byte, error) {
+	var doc interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	return json.Marshal(doc)
+}
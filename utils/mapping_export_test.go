@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bytes"
+	"data_mapping/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// publishedRules are rules as a published version stores them, with the
+// sub-mapping expanded into the rule's SubRules.
+func publishedRules() []models.MappingRule {
+	address := models.MappingRuleList{
+		{SourcePath: models.JSONStringList{"city"}, DestinationPath: models.JSONStringList{"town"}, TransformType: "copy"},
+	}
+	return []models.MappingRule{
+		{ID: 4, ClientID: 1, SourcePath: models.JSONStringList{"id"}, DestinationPath: models.JSONStringList{"ref"}, TransformType: "toString"},
+		{ID: 5, ClientID: 1, SourcePath: models.JSONStringList{"home"}, DestinationPath: models.JSONStringList{"address"}, TransformType: "subMapping", TransformLogic: "address", SubRules: address},
+		{ID: 6, ClientID: 1, SourcePath: models.JSONStringList{"people"}, DestinationPath: models.JSONStringList{"byTeam"}, TransformType: "group", TransformLogic: "team", SubRules: models.MappingRuleList{
+			{SourcePath: models.JSONStringList{"name"}, DestinationPath: models.JSONStringList{"name"}, TransformType: "copy"},
+			{SourcePath: models.JSONStringList{"home"}, DestinationPath: models.JSONStringList{"address"}, TransformType: "subMapping", TransformLogic: "address", SubRules: address},
+		}},
+	}
+}
+
+func TestCollapseSubMappings(t *testing.T) {
+	rules := publishedRules()
+	collapsed := CollapseSubMappings(rules)
+
+	if collapsed[1].SubRules != nil || collapsed[2].SubRules[1].SubRules != nil {
+		t.Errorf("collapsed = %+v, want subMapping rules without sub-rules", collapsed)
+	}
+	if len(collapsed[2].SubRules) != 2 {
+		t.Errorf("group sub-rules = %+v, want both kept", collapsed[2].SubRules)
+	}
+	if !reflect.DeepEqual(rules, publishedRules()) {
+		t.Error("CollapseSubMappings changed its input")
+	}
+}
+
+// A published version exports in a form the import accepts again.
+func TestMappingExportRoundTrip(t *testing.T) {
+	for _, format := range []string{"json", "yaml", "csv"} {
+		var buf bytes.Buffer
+		if err := WriteMappingExport(&buf, NewMappingExport("acme", 3, false, publishedRules()), format); err != nil {
+			t.Fatalf("%s: %v", format, err)
+		}
+
+		var rules []models.MappingRule
+		var errs []RuleError
+		var err error
+		switch format {
+		case "csv":
+			rules, errs, err = ParseMappingRulesCSV(strings.NewReader(buf.String()))
+		case "yaml":
+			var data []byte
+			if data, err = YAMLToJSON(buf.Bytes()); err == nil {
+				rules, errs, err = DecodeMappingRules(data)
+			}
+		default:
+			rules, errs, err = DecodeMappingRules(buf.Bytes())
+		}
+		if err != nil || errs != nil {
+			t.Fatalf("%s: err = %v, rule errors = %v", format, err, errs)
+		}
+
+		for i, rule := range rules {
+			if err := ValidateMappingRule(rule); err != nil {
+				t.Errorf("%s: rules[%d]: %v", format, i, err)
+			}
+		}
+		want := CollapseSubMappings(publishedRules())
+		for i := range want {
+			want[i].ID, want[i].ClientID = 0, 0
+		}
+		if !reflect.DeepEqual(rules, want) {
+			t.Errorf("%s: imported\n%+v\nwant\n%+v", format, rules, want)
+		}
+	}
+}
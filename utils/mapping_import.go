@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"bytes"
+	"data_mapping/models"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// RuleError reports a problem with one rule of an imported mapping set.
+type RuleError struct {
+	Index   int    `json:"index"`
+	Error   string `json:"error"`
+	Details string `json:"details,omitempty"`
+}
+
+// DecodeMappingRules decodes a JSON array of mapping rules, or an export
+// document holding one under "rules", one element at a time so that every
+// malformed rule is reported, not just the first.
+func DecodeMappingRules(data []byte) ([]models.MappingRule, []RuleError, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var export struct {
+			Rules []json.RawMessage `json:"rules"`
+		}
+		if json.Unmarshal(data, &export) != nil || export.Rules == nil {
+			return nil, nil, fmt.Errorf("expected a JSON array of mapping rules or an export document")
+		}
+		raw = export.Rules
+	}
+
+	rules := make([]models.MappingRule, len(raw))
+	var errs []RuleError
+	for i, item := range raw {
+		if err := decodeRule(item, &rules[i]); err != nil {
+			errs = append(errs, RuleError{Index: i, Error: "Invalid rule", Details: err.Error()})
+		}
+	}
+	return rules, errs, nil
+}
+
+// ParseMappingRulesCSV reads mapping rules from CSV whose header row uses the
+// rule's JSON field names. Lines starting with '#' are comments. Text columns
+// are taken verbatim, whitespace included, so exports round-trip. Boolean
+// and numeric columns are parsed, and other columns such as paths are
+// trimmed and read as JSON when the cell starts with '{' or '[' and as a
+// dotted string otherwise.
+func ParseMappingRulesCSV(r io.Reader) ([]models.MappingRule, []RuleError, error) {
+	reader := csv.NewReader(r)
+	reader.Comment = '#'
+	reader.FieldsPerRecord = -1
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(rows) == 0 {
+		return nil, nil, fmt.Errorf("CSV input has no header row")
+	}
+
+	header := rows[0]
+	if len(header) > 0 {
+		header[0] = strings.TrimPrefix(header[0], "\ufeff")
+	}
+	kinds := ruleFieldKinds()
+
+	rules := make([]models.MappingRule, len(rows)-1)
+	var errs []RuleError
+	for i, row := range rows[1:] {
+		fields := make(map[string]json.RawMessage)
+		var rowErr error
+		for col, cell := range row {
+			if col >= len(header) {
+				rowErr = fmt.Errorf("row has more fields than the header")
+				break
+			}
+			name := strings.TrimSpace(header[col])
+			kind := kinds[name]
+			if kind != reflect.String {
+				cell = strings.TrimSpace(cell)
+			}
+			if name == "" || cell == "" {
+				continue
+			}
+			switch {
+			case kind == reflect.String:
+				fields[name], _ = json.Marshal(cell)
+			case kind == reflect.Bool:
+				b, err := strconv.ParseBool(cell)
+				if err != nil {
+					rowErr = fmt.Errorf("column %s: %q is not a boolean", name, cell)
+				}
+				fields[name], _ = json.Marshal(b)
+			case kind >= reflect.Int && kind <= reflect.Float64:
+				if !json.Valid([]byte(cell)) {
+					rowErr = fmt.Errorf("column %s: %q is not a number", name, cell)
+				}
+				fields[name] = json.RawMessage(cell)
+			case strings.HasPrefix(cell, "{") || strings.HasPrefix(cell, "["):
+				if !json.Valid([]byte(cell)) {
+					rowErr = fmt.Errorf("column %s: invalid JSON", name)
+				}
+				fields[name] = json.RawMessage(cell)
+			default:
+				fields[name], _ = json.Marshal(cell)
+			}
+		}
+		if rowErr == nil {
+			data, _ := json.Marshal(fields)
+			rowErr = decodeRule(data, &rules[i])
+		}
+		if rowErr != nil {
+			errs = append(errs, RuleError{Index: i, Error: "Invalid rule", Details: rowErr.Error()})
+		}
+	}
+	return rules, errs, nil
+}
+
+func decodeRule(data []byte, rule *models.MappingRule) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	return dec.Decode(rule)
+}
+
+// ruleFieldKinds maps the JSON names of MappingRule fields to their kinds.
+func ruleFieldKinds() map[string]reflect.Kind {
+	kinds := make(map[string]reflect.Kind)
+	t := reflect.TypeOf(models.MappingRule{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name != "" && name != "-" {
+			kinds[name] = field.Type.Kind()
+		}
+	}
+	return kinds
+}
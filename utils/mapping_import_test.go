@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeMappingRules(t *testing.T) {
+	want := []models.MappingRule{
+		{SourcePath: models.JSONStringList{"loan", "amount"}, DestinationPath: models.JSONStringList{"amount"}, TransformType: "copy"},
+		{SourcePath: models.JSONStringList{"a.b"}, DestinationPath: models.JSONStringList{"x", "y"}, TransformType: "toString"},
+	}
+	for name, body := range map[string]string{
+		"array": `[
+			{"source_path": "loan.amount", "destination_path": "amount", "transform_type": "copy"},
+			{"source_path": ["a.b"], "destination_path": ["x", "y"], "transform_type": "toString"}
+		]`,
+		"export": `{"client": "acme", "version": 2, "rules": [
+			{"source_path": "loan.amount", "destination_path": "amount", "transform_type": "copy"},
+			{"source_path": ["a.b"], "destination_path": "x.y", "transform_type": "toString"}
+		]}`,
+	} {
+		rules, errs, err := DecodeMappingRules([]byte(body))
+		if err != nil || errs != nil {
+			t.Fatalf("%s: err = %v, rule errors = %v", name, err, errs)
+		}
+		if !reflect.DeepEqual(rules, want) {
+			t.Errorf("%s: rules =\n%+v\nwant\n%+v", name, rules, want)
+		}
+	}
+}
+
+func TestDecodeMappingRulesReportsEveryBadRule(t *testing.T) {
+	_, errs, err := DecodeMappingRules([]byte(`[
+		{"source_path": 1, "destination_path": "a", "transform_type": "copy"},
+		{"source_path": "a", "destination_path": "b", "transform_type": "copy"},
+		{"source_path": "a", "destination_path": "c", "transform_type": "copy", "unknown": true}
+	]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(errs) != 2 || errs[0].Index != 0 || errs[1].Index != 2 {
+		t.Errorf("rule errors = %+v, want rules 0 and 2", errs)
+	}
+
+	if _, _, err := DecodeMappingRules([]byte(`{"rules": "none"}`)); err == nil {
+		t.Error("document without a rules array accepted")
+	}
+}
+
+func TestParseMappingRulesCSV(t *testing.T) {
+	input := "\ufeffsource_path, destination_path ,transform_type,default_value,required,set\n" +
+		"# comment line\n" +
+		` loan.amount ,"[""out"", ""amount""]",copy,  n/a ,true,` + "\n" +
+		"name,name,toUpperCase,,false,retail\n"
+
+	rules, errs, err := ParseMappingRulesCSV(strings.NewReader(input))
+	if err != nil || errs != nil {
+		t.Fatalf("err = %v, rule errors = %v", err, errs)
+	}
+	// Paths are trimmed but text cells such as default_value keep their
+	// whitespace
+	want := []models.MappingRule{
+		{SourcePath: models.JSONStringList{"loan", "amount"}, DestinationPath: models.JSONStringList{"out", "amount"}, TransformType: "copy", DefaultValue: "  n/a ", Required: true},
+		{SourcePath: models.JSONStringList{"name"}, DestinationPath: models.JSONStringList{"name"}, TransformType: "toUpperCase", Set: "retail"},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Errorf("rules =\n%+v\nwant\n%+v", rules, want)
+	}
+}
+
+func TestParseMappingRulesCSVReportsBadRows(t *testing.T) {
+	input := "source_path,destination_path,transform_type,required\n" +
+		"a,b,copy,maybe\n" +
+		"a,{b,copy,false\n" +
+		"a,b,copy,false,extra\n" +
+		"a,b,copy,false\n"
+
+	_, errs, err := ParseMappingRulesCSV(strings.NewReader(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var indexes []int
+	for _, e := range errs {
+		indexes = append(indexes, e.Index)
+	}
+	if !reflect.DeepEqual(indexes, []int{0, 1, 2}) {
+		t.Errorf("rule errors = %+v, want rows 0, 1 and 2", errs)
+	}
+
+	if _, _, err := ParseMappingRulesCSV(strings.NewReader("")); err == nil {
+		t.Error("empty CSV accepted")
+	}
+}
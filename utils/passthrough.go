@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ApplyPassThrough copies every input leaf below the configured source that
+// no rule reads into output under the configured target. Values written by
+// rules always win: a leaf is skipped when its destination, or a scalar on
+// the way to it, is already present in output.
+func ApplyPassThrough(output, input map[string]interface{}, rules []models.MappingRule, opts models.PassThrough) {
+	if !opts.Enabled {
+		return
+	}
+
+	consumed := inputReads(input, rules)
+	source := models.ParsePath(opts.Source)
+	target := models.ParsePath(opts.Target)
+	excludes := make([][]string, len(opts.Exclude))
+	for i, pattern := range opts.Exclude {
+		excludes[i] = models.ParsePath(pattern)
+	}
+
+	var root interface{} = input
+	if len(source) > 0 {
+		var exists bool
+		if root, exists = GetNestedValue(input, source); !exists {
+			return
+		}
+	}
+	for _, rel := range LeafPaths(root) {
+		leaf := append(append([]string{}, source...), rel...)
+		if len(leaf) == 0 || pathConsumed(leaf, consumed) || pathExcluded(leaf, excludes) {
+			continue
+		}
+		dest := append(append([]string{}, target...), rel...)
+		if len(dest) == 0 || !pathFree(output, dest) {
+			continue
+		}
+		value, _ := GetNestedValue(input, leaf)
+		SetPathValue(output, dest, value)
+	}
+}
+
+// ValidatePassThrough checks that the configured paths and patterns have no
+// empty keys.
+func ValidatePassThrough(opts models.PassThrough) error {
+	for name, path := range map[string]string{"source": opts.Source, "target": opts.Target} {
+		if path != "" && hasEmptyKey(path) {
+			return fmt.Errorf("pass_through.%s %q has an empty key", name, path)
+		}
+	}
+	for _, pattern := range opts.Exclude {
+		if pattern == "" || hasEmptyKey(pattern) {
+			return fmt.Errorf("pass_through.exclude pattern %q has an empty key", pattern)
+		}
+	}
+	return nil
+}
+
+func hasEmptyKey(path string) bool {
+	for _, key := range strings.Split(path, ".") {
+		if key == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// pathExcluded reports whether path or one of its ancestors matches any of
+// the exclusion patterns.
+func pathExcluded(path []string, patterns [][]string) bool {
+	for _, pattern := range patterns {
+		if len(pattern) == 1 && pattern[0] != "*" && pattern[0] != "**" {
+			for _, key := range path {
+				if key == pattern[0] {
+					return true
+				}
+			}
+			continue
+		}
+		for n := 1; n <= len(path); n++ {
+			if matchPattern(pattern, path[:n]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchPattern matches a whole path against a pattern of keys, "*" and "**".
+func matchPattern(pattern, path []string) bool {
+	if len(pattern) == 0 {
+		return len(path) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(path); i++ {
+			if matchPattern(pattern[1:], path[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(path) == 0 || (pattern[0] != "*" && pattern[0] != path[0]) {
+		return false
+	}
+	return matchPattern(pattern[1:], path[1:])
+}
+
+// pathFree reports whether path can be written in data without replacing an
+// existing value.
+func pathFree(data map[string]interface{}, path []string) bool {
+	var current interface{} = data
+	for _, key := range path {
+		switch v := current.(type) {
+		case map[string]interface{}:
+			next, ok := v[key]
+			if !ok {
+				return true
+			}
+			current = next
+		case []interface{}:
+			idx, err := strconv.Atoi(key)
+			if err != nil || idx < 0 {
+				return false
+			}
+			if idx >= len(v) {
+				return true
+			}
+			current = v[idx]
+		case nil:
+			return true
+		default:
+			return false
+		}
+	}
+	return false
+}
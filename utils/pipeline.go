@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// defaultPipeline is the pipeline of clients that configure none.
+var defaultPipeline = []models.PipelineStage{{Type: "map"}, {Type: "validate"}}
+
+// pipelineStages returns the stages a pipeline runs: the default pipeline
+// when it has none, else its stages ending with a validate stage.
+func pipelineStages(pipeline models.Pipeline) []models.PipelineStage {
+	stages := pipeline.Stages
+	if len(stages) == 0 {
+		return defaultPipeline
+	}
+	if stages[len(stages)-1].Type != "validate" {
+		stages = append(append([]models.PipelineStage{}, stages...), models.PipelineStage{Type: "validate"})
+	}
+	return stages
+}
+
+// StageTrace records what one pipeline stage did to a document. Normalize
+// and postprocess stages list the values they changed, the map stage lists
+// what each rule read and wrote, and the validate stage lists the rule
+// violations of the output or reports that it was skipped.
+type StageTrace struct {
+	Stage      string                `json:"stage"`
+	Skipped    bool                  `json:"skipped,omitempty"`
+	Changes    []TraceChange         `json:"changes,omitempty"`
+	Rules      []RuleTrace           `json:"rules,omitempty"`
+	Violations []ConstraintViolation `json:"violations,omitempty"`
+}
+
+// TraceChange is a value a stage trimmed, nulled, removed or patched. Path
+// is dotted, with array elements addressed by index.
+type TraceChange struct {
+	Path   string      `json:"path"`
+	Action string      `json:"action"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// RuleTrace is the value a rule read from the input and the value left at
+// its destination once all rules ran.
+type RuleTrace struct {
+	Index       int         `json:"index"`
+	Source      string      `json:"source"`
+	Destination string      `json:"destination"`
+	Found       bool        `json:"found"`
+	Value       interface{} `json:"value,omitempty"`
+	Result      interface{} `json:"result,omitempty"`
+}
+
+// ValidatePipeline checks the stage types, their order and that each stage
+// only sets the options of its type.
+func ValidatePipeline(pipeline models.Pipeline) error {
+	if len(pipeline.Stages) == 0 {
+		return nil
+	}
+	maps := 0
+	for i, stage := range pipeline.Stages {
+		var misplaced []string
+		switch stage.Type {
+		case "normalize":
+			if maps > 0 {
+				return fmt.Errorf("stages[%d]: normalize stages must come before the map stage", i)
+			}
+		case "map":
+			maps++
+		case "postprocess", "validate":
+			if maps == 0 {
+				return fmt.Errorf("stages[%d]: %s stages must come after the map stage", i, stage.Type)
+			}
+			if stage.Type == "validate" && i != len(pipeline.Stages)-1 {
+				return fmt.Errorf("stages[%d]: validate must be the last stage", i)
+			}
+		default:
+			return fmt.Errorf("stages[%d]: unknown stage type %q", i, stage.Type)
+		}
+		if stage.Type != "normalize" && stage.Trim {
+			misplaced = append(misplaced, "trim")
+		}
+		if stage.Type != "normalize" && len(stage.Placeholders) > 0 {
+			misplaced = append(misplaced, "placeholders")
+		}
+		if stage.Type != "postprocess" && stage.RemoveNulls {
+			misplaced = append(misplaced, "remove_nulls")
+		}
+		if stage.Type != "postprocess" && stage.MergePatch != nil {
+			misplaced = append(misplaced, "merge_patch")
+		}
+		if stage.Type != "validate" && stage.Strict {
+			misplaced = append(misplaced, "strict")
+		}
+		if stage.Type != "validate" && stage.Skip {
+			misplaced = append(misplaced, "skip")
+		}
+		if stage.Strict && stage.Skip {
+			return fmt.Errorf("stages[%d]: strict and skip cannot both be set", i)
+		}
+		if len(misplaced) > 0 {
+			return fmt.Errorf("stages[%d]: %s not allowed on a %s stage", i, strings.Join(misplaced, ", "), stage.Type)
+		}
+	}
+	if maps != 1 {
+		return fmt.Errorf("pipeline must have exactly one map stage, found %d", maps)
+	}
+	return nil
+}
+
+// TransformTraced runs the client pipeline on input. The input is never
+// modified. With opts.Explain set it also returns a trace of every stage.
+func TransformTraced(input map[string]interface{}, rules []models.MappingRule, opts TransformOptions) (map[string]interface{}, []StageTrace, error) {
+	stages := pipelineStages(opts.Pipeline)
+
+	var traces []StageTrace
+	var output map[string]interface{}
+	for _, stage := range stages {
+		trace := StageTrace{Stage: stage.Type}
+		var changes *[]TraceChange
+		if opts.Explain {
+			changes = &trace.Changes
+		}
+		switch stage.Type {
+		case "normalize":
+			input = normalizeValue(input, "", stage, changes).(map[string]interface{})
+		case "map":
+			var err error
+			if output, err = NewTransformer(rules, opts).Transform(input); err != nil {
+				return nil, nil, err
+			}
+			ApplyPassThrough(output, input, rules, opts.PassThrough)
+			if opts.Explain {
+				trace.Rules = traceRules(input, output, rules)
+			}
+		case "postprocess":
+			if stage.RemoveNulls {
+				output = removeNulls(output, "", changes).(map[string]interface{})
+			}
+			if stage.MergePatch != nil {
+				output = mergePatch(output, stage.MergePatch, "", changes)
+			}
+		case "validate":
+			// Schemas are checked by the caller, which can load them
+			trace.Skipped = stage.Skip
+			if opts.Explain && !stage.Skip {
+				trace.Violations = ValidateOutput(output, rules)
+			}
+		}
+		if opts.Explain {
+			traces = append(traces, trace)
+		}
+	}
+	return output, traces, nil
+}
+
+// normalizeValue returns a copy of v with the normalize stage applied to
+// every string. Changes are recorded when changes is not nil.
+func normalizeValue(v interface{}, path string, stage models.PipelineStage, changes *[]TraceChange) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for _, k := range sortedKeys(val) {
+			out[k] = normalizeValue(val[k], joinDotted(path, k), stage, changes)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, elem := range val {
+			out[i] = normalizeValue(elem, joinDotted(path, strconv.Itoa(i)), stage, changes)
+		}
+		return out
+	case string:
+		trimmed := strings.TrimSpace(val)
+		for _, placeholder := range stage.Placeholders {
+			if trimmed == strings.TrimSpace(placeholder) {
+				recordChange(changes, TraceChange{Path: path, Action: "nulled", Before: val})
+				return nil
+			}
+		}
+		if stage.Trim && trimmed != val {
+			recordChange(changes, TraceChange{Path: path, Action: "trimmed", Before: val, After: trimmed})
+			return trimmed
+		}
+	}
+	return v
+}
+
+// removeNulls returns a copy of v without null object members. Nulls in
+// arrays are kept so element positions do not shift.
+func removeNulls(v interface{}, path string, changes *[]TraceChange) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for _, k := range sortedKeys(val) {
+			if val[k] == nil {
+				recordChange(changes, TraceChange{Path: joinDotted(path, k), Action: "removed"})
+				continue
+			}
+			out[k] = removeNulls(val[k], joinDotted(path, k), changes)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, elem := range val {
+			out[i] = removeNulls(elem, joinDotted(path, strconv.Itoa(i)), changes)
+		}
+		return out
+	}
+	return v
+}
+
+// mergePatch applies patch to a copy of target as RFC 7386 describes: null
+// members delete, object members merge recursively and anything else
+// replaces the target value.
+func mergePatch(target, patch map[string]interface{}, path string, changes *[]TraceChange) map[string]interface{} {
+	out := make(map[string]interface{}, len(target)+len(patch))
+	for k, v := range target {
+		out[k] = v
+	}
+	for _, k := range sortedKeys(patch) {
+		at := joinDotted(path, k)
+		before, exists := out[k]
+		switch p := patch[k].(type) {
+		case nil:
+			if exists {
+				delete(out, k)
+				recordChange(changes, TraceChange{Path: at, Action: "removed", Before: before})
+			}
+		case map[string]interface{}:
+			nested, ok := before.(map[string]interface{})
+			if !ok {
+				if exists {
+					recordChange(changes, TraceChange{Path: at, Action: "patched", Before: before})
+				}
+				nested = map[string]interface{}{}
+			}
+			out[k] = mergePatch(nested, p, at, changes)
+		default:
+			after := normalizeJSON(p)
+			out[k] = after
+			recordChange(changes, TraceChange{Path: at, Action: "patched", Before: before, After: after})
+		}
+	}
+	return out
+}
+
+// traceRules reports, for each rule, the value it read and the value at its
+// destination in the finished output.
+func traceRules(input, output map[string]interface{}, rules []models.MappingRule) []RuleTrace {
+	traces := make([]RuleTrace, len(rules))
+	for i, rule := range rules {
+		value, found := ResolveSourcePath(input, rule.SourcePath)
+		result, _ := GetNestedValue(output, rule.DestinationPath)
+		traces[i] = RuleTrace{
+			Index:       i,
+			Source:      strings.Join(rule.SourcePath, "."),
+			Destination: strings.Join(rule.DestinationPath, "."),
+			Found:       found,
+			Value:       value,
+			Result:      result,
+		}
+	}
+	return traces
+}
+
+func recordChange(changes *[]TraceChange, change TraceChange) {
+	if changes != nil {
+		*changes = append(*changes, change)
+	}
+}
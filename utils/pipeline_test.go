@@ -0,0 +1,221 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		target      string
+		patch       string
+		want        string
+		wantChanges []TraceChange
+	}{
+		{
+			name:        "replace member",
+			target:      `{"a": "b"}`,
+			patch:       `{"a": "c"}`,
+			want:        `{"a": "c"}`,
+			wantChanges: []TraceChange{{Path: "a", Action: "patched", Before: "b", After: "c"}},
+		},
+		{
+			name:        "add member",
+			target:      `{"a": "b"}`,
+			patch:       `{"b": "c"}`,
+			want:        `{"a": "b", "b": "c"}`,
+			wantChanges: []TraceChange{{Path: "b", Action: "patched", After: "c"}},
+		},
+		{
+			name:        "null deletes",
+			target:      `{"a": "b", "b": "c"}`,
+			patch:       `{"a": null}`,
+			want:        `{"b": "c"}`,
+			wantChanges: []TraceChange{{Path: "a", Action: "removed", Before: "b"}},
+		},
+		{
+			name:   "null for a missing member is a no-op",
+			target: `{"a": "b"}`,
+			patch:  `{"c": null}`,
+			want:   `{"a": "b"}`,
+		},
+		{
+			name:        "array replaces array",
+			target:      `{"a": ["b"]}`,
+			patch:       `{"a": ["c", "d"]}`,
+			want:        `{"a": ["c", "d"]}`,
+			wantChanges: []TraceChange{{Path: "a", Action: "patched", Before: []interface{}{"b"}, After: []interface{}{"c", "d"}}},
+		},
+		{
+			name:        "object replaces scalar",
+			target:      `{"a": "c"}`,
+			patch:       `{"a": {"b": "c"}}`,
+			want:        `{"a": {"b": "c"}}`,
+			wantChanges: []TraceChange{{Path: "a", Action: "patched", Before: "c"}, {Path: "a.b", Action: "patched", After: "c"}},
+		},
+		{
+			name:   "nested merge in key order",
+			target: `{"title": "Goodbye!", "author": {"givenName": "John", "familyName": "Doe"}, "tags": ["example", "sample"]}`,
+			patch:  `{"title": "Hello!", "phoneNumber": "+01-123-456-7890", "author": {"familyName": null}, "tags": ["example"]}`,
+			want:   `{"title": "Hello!", "author": {"givenName": "John"}, "tags": ["example"], "phoneNumber": "+01-123-456-7890"}`,
+			wantChanges: []TraceChange{
+				{Path: "author.familyName", Action: "removed", Before: "Doe"},
+				{Path: "phoneNumber", Action: "patched", After: "+01-123-456-7890"},
+				{Path: "tags", Action: "patched", Before: []interface{}{"example", "sample"}, After: []interface{}{"example"}},
+				{Path: "title", Action: "patched", Before: "Goodbye!", After: "Hello!"},
+			},
+		},
+		{
+			name:        "nested nulls inside a new object are dropped",
+			target:      `{}`,
+			patch:       `{"a": {"bb": {"ccc": null}}}`,
+			want:        `{"a": {"bb": {}}}`,
+			wantChanges: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target := mustJSON(t, tt.target).(map[string]interface{})
+			original := mustJSON(t, tt.target)
+			var changes []TraceChange
+
+			got := mergePatch(target, mustJSON(t, tt.patch).(map[string]interface{}), "", &changes)
+			if want := mustJSON(t, tt.want); !reflect.DeepEqual(normalizeJSON(got), want) {
+				t.Errorf("result = %v, want %v", got, want)
+			}
+			if !reflect.DeepEqual(changes, tt.wantChanges) {
+				t.Errorf("changes = %+v, want %+v", changes, tt.wantChanges)
+			}
+			if !reflect.DeepEqual(normalizeJSON(target), original) {
+				t.Errorf("target was modified: %v", target)
+			}
+		})
+	}
+}
+
+func TestTransformTracedRunsStagesInOrder(t *testing.T) {
+	input := mustJSON(t, `{"name": "  Asha ", "pan": "NA", "city": "Pune"}`).(map[string]interface{})
+	rules := []models.MappingRule{
+		{SourcePath: []string{"name"}, DestinationPath: []string{"applicant", "name"}, TransformType: "copy"},
+		{SourcePath: []string{"pan"}, DestinationPath: []string{"applicant", "pan"}, TransformType: "copy"},
+		{SourcePath: []string{"city"}, DestinationPath: []string{"address", "city"}, TransformType: "copy"},
+	}
+	pipeline := models.Pipeline{Stages: []models.PipelineStage{
+		{Type: "normalize", Trim: true, Placeholders: []string{"NA"}},
+		{Type: "map"},
+		{Type: "postprocess", RemoveNulls: true},
+		{Type: "postprocess", MergePatch: map[string]interface{}{"address": nil, "source": "los"}},
+	}}
+
+	output, traces, err := TransformTraced(input, rules, TransformOptions{Pipeline: pipeline, Explain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := mustJSON(t, `{"applicant": {"name": "Asha"}, "source": "los"}`)
+	if !reflect.DeepEqual(normalizeJSON(output), want) {
+		t.Errorf("output = %v, want %v", output, want)
+	}
+	if input["name"] != "  Asha " {
+		t.Errorf("input was modified: %v", input)
+	}
+
+	var stages []string
+	for _, trace := range traces {
+		stages = append(stages, trace.Stage)
+	}
+	// A pipeline without a validate stage ends with the default one
+	if !reflect.DeepEqual(stages, []string{"normalize", "map", "postprocess", "postprocess", "validate"}) {
+		t.Fatalf("stages = %v", stages)
+	}
+	wantNormalize := []TraceChange{
+		{Path: "name", Action: "trimmed", Before: "  Asha ", After: "Asha"},
+		{Path: "pan", Action: "nulled", Before: "NA"},
+	}
+	if !reflect.DeepEqual(traces[0].Changes, wantNormalize) {
+		t.Errorf("normalize changes = %+v, want %+v", traces[0].Changes, wantNormalize)
+	}
+	if len(traces[1].Rules) != 3 || traces[1].Rules[1].Found != true || traces[1].Rules[1].Value != nil {
+		t.Errorf("map trace = %+v, want the nulled pan found with no value", traces[1].Rules)
+	}
+	if want := []TraceChange{{Path: "applicant.pan", Action: "removed"}}; !reflect.DeepEqual(traces[2].Changes, want) {
+		t.Errorf("remove_nulls changes = %+v, want %+v", traces[2].Changes, want)
+	}
+
+	// Without explain there is no trace
+	if _, traces, _ := TransformTraced(input, rules, TransformOptions{Pipeline: pipeline}); traces != nil {
+		t.Errorf("traces = %+v without explain", traces)
+	}
+}
+
+func TestValidatePipeline(t *testing.T) {
+	stages := func(types ...string) models.Pipeline {
+		var p models.Pipeline
+		for _, typ := range types {
+			p.Stages = append(p.Stages, models.PipelineStage{Type: typ})
+		}
+		return p
+	}
+
+	for _, valid := range []models.Pipeline{
+		{},
+		stages("map"),
+		stages("normalize", "normalize", "map", "postprocess", "validate"),
+	} {
+		if err := ValidatePipeline(valid); err != nil {
+			t.Errorf("ValidatePipeline(%v): %v", valid.Stages, err)
+		}
+	}
+
+	invalid := map[string]models.Pipeline{
+		"no map stage":        stages("normalize", "validate"),
+		"two map stages":      stages("map", "map"),
+		"normalize after map": stages("map", "normalize"),
+		"postprocess first":   stages("postprocess", "map"),
+		"validate not last":   stages("map", "validate", "postprocess"),
+		"unknown stage":       stages("map", "enrich"),
+		"misplaced option":    {Stages: []models.PipelineStage{{Type: "map", Trim: true}}},
+		"strict on postprocess": {Stages: []models.PipelineStage{
+			{Type: "map"}, {Type: "postprocess", Strict: true},
+		}},
+	}
+	for name, pipeline := range invalid {
+		if err := ValidatePipeline(pipeline); err == nil {
+			t.Errorf("%s: ValidatePipeline accepted %v", name, pipeline.Stages)
+		}
+	}
+}
+
+func TestTransformTracedSkippedValidation(t *testing.T) {
+	input := map[string]interface{}{"amount": "lots"}
+	rules := []models.MappingRule{{
+		SourcePath:      []string{"amount"},
+		DestinationPath: []string{"amount"},
+		TransformType:   "copy",
+		Constraints:     &models.Constraints{Type: "number"},
+	}}
+
+	_, traces, err := TransformTraced(input, rules, TransformOptions{Explain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := traces[len(traces)-1]; last.Stage != "validate" || last.Skipped || len(last.Violations) == 0 {
+		t.Errorf("default validate stage = %+v, want the constraint violation", last)
+	}
+
+	skip := models.Pipeline{Stages: []models.PipelineStage{{Type: "map"}, {Type: "validate", Skip: true}}}
+	_, traces, err = TransformTraced(input, rules, TransformOptions{Pipeline: skip, Explain: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := traces[len(traces)-1]; !last.Skipped || len(last.Violations) != 0 {
+		t.Errorf("skipped validate stage = %+v, want it marked skipped without violations", last)
+	}
+	if skip.Validates() {
+		t.Error("Validates() = true for a pipeline that skips validation")
+	}
+	if err := ValidatePipeline(models.Pipeline{Stages: []models.PipelineStage{{Type: "map"}, {Type: "validate", Skip: true, Strict: true}}}); err == nil {
+		t.Error("ValidatePipeline accepted strict together with skip")
+	}
+}
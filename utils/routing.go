@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"errors"
+	"fmt"
+
+	"github.com/antonmedv/expr"
+)
+
+// ErrUnknownMappingSet is wrapped by the errors returned when the set picked
+// for a transform has no rules.
+var ErrUnknownMappingSet = errors.New("unknown mapping set")
+
+// ValidateRouting checks that every route names its condition, that the
+// condition compiles and that the named sets of the routes and the default
+// are among sets.
+func ValidateRouting(routing models.Routing, sets []string) error {
+	known := make(map[string]bool, len(sets))
+	for _, set := range sets {
+		known[set] = true
+	}
+	for i, route := range routing.Routes {
+		if route.When == "" {
+			return fmt.Errorf("routes[%d]: when is required", i)
+		}
+		if _, err := expr.Compile(route.When); err != nil {
+			return fmt.Errorf("routes[%d]: invalid when expression: %s", i, err.Error())
+		}
+		if route.Set != "" && !known[route.Set] {
+			return fmt.Errorf("routes[%d]: %w %q", i, ErrUnknownMappingSet, route.Set)
+		}
+	}
+	if routing.Default != "" && !known[routing.Default] {
+		return fmt.Errorf("default: %w %q", ErrUnknownMappingSet, routing.Default)
+	}
+	return nil
+}
+
+// MappingSets lists the set names used by rules in order of first use. The
+// unnamed set is listed as "".
+func MappingSets(rules []models.MappingRule) []string {
+	seen := make(map[string]bool)
+	var sets []string
+	for _, rule := range rules {
+		if !seen[rule.Set] {
+			seen[rule.Set] = true
+			sets = append(sets, rule.Set)
+		}
+	}
+	return sets
+}
+
+// RulesInSet returns the rules belonging to the named set, in order.
+func RulesInSet(rules []models.MappingRule, set string) []models.MappingRule {
+	var selected []models.MappingRule
+	for _, rule := range rules {
+		if rule.Set == set {
+			selected = append(selected, rule)
+		}
+	}
+	return selected
+}
+
+// RouteMappingSet picks the mapping set for input: override when it is not
+// empty, else the first route whose condition holds, else the routing
+// default. It returns the set's name and rules.
+func RouteMappingSet(input map[string]interface{}, rules []models.MappingRule, routing models.Routing, override string) (string, []models.MappingRule, error) {
+	set := routing.Default
+	if override != "" {
+		set = override
+	} else {
+		for i, route := range routing.Routes {
+			result, err := EvaluateExpression(route.When, map[string]interface{}{"input": input})
+			if err != nil {
+				return "", nil, fmt.Errorf("routes[%d]: %s", i, err.Error())
+			}
+			matched, ok := result.(bool)
+			if !ok {
+				return "", nil, fmt.Errorf("routes[%d]: when expression returned %T, not a boolean", i, result)
+			}
+			if matched {
+				set = route.Set
+				break
+			}
+		}
+	}
+
+	selected := RulesInSet(rules, set)
+	if len(selected) == 0 {
+		return "", nil, fmt.Errorf("%w %q", ErrUnknownMappingSet, set)
+	}
+	return set, selected, nil
+}
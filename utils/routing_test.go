@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"errors"
+	"testing"
+)
+
+func TestRouteMappingSet(t *testing.T) {
+	inSet := func(rule models.MappingRule, set string) models.MappingRule {
+		rule.Set = set
+		return rule
+	}
+	rules := []models.MappingRule{
+		copyRule("loanId", "id"),
+		inSet(copyRule("loanId", "id"), "secured"),
+		inSet(copyRule("collateralDetails.type", "collateral"), "secured"),
+	}
+	routing := models.Routing{
+		Routes: []models.Route{{Set: "secured", When: "input.collateralDetails != nil"}},
+	}
+
+	set, selected, err := RouteMappingSet(map[string]interface{}{"collateralDetails": map[string]interface{}{}}, rules, routing, "")
+	if err != nil || set != "secured" || len(selected) != 2 {
+		t.Errorf("matching route: set %q, %d rules, err %v; want secured with 2 rules", set, len(selected), err)
+	}
+
+	set, selected, err = RouteMappingSet(map[string]interface{}{}, rules, routing, "")
+	if err != nil || set != "" || len(selected) != 1 {
+		t.Errorf("no route: set %q, %d rules, err %v; want the unnamed default with 1 rule", set, len(selected), err)
+	}
+
+	set, _, err = RouteMappingSet(map[string]interface{}{}, rules, routing, "secured")
+	if err != nil || set != "secured" {
+		t.Errorf("override: set %q, err %v; want secured", set, err)
+	}
+
+	if _, _, err = RouteMappingSet(map[string]interface{}{}, rules, routing, "unsecured"); !errors.Is(err, ErrUnknownMappingSet) {
+		t.Errorf("unknown override: err %v, want ErrUnknownMappingSet", err)
+	}
+
+	notBool := models.Routing{Routes: []models.Route{{Set: "secured", When: "input.loanId"}}}
+	if _, _, err = RouteMappingSet(map[string]interface{}{"loanId": "L1"}, rules, notBool, ""); err == nil {
+		t.Error("non-boolean when expression accepted")
+	}
+}
+
+func TestMappingSetsKeepsFirstUseOrder(t *testing.T) {
+	rules := []models.MappingRule{{Set: "b"}, {Set: ""}, {Set: "a"}, {Set: "b"}}
+	sets := MappingSets(rules)
+	if len(sets) != 3 || sets[0] != "b" || sets[1] != "" || sets[2] != "a" {
+		t.Errorf("MappingSets = %q, want [b  a]", sets)
+	}
+}
+
+func TestValidateRouting(t *testing.T) {
+	sets := []string{"", "secured"}
+	route := func(set, when string) models.Routing {
+		return models.Routing{Routes: []models.Route{{Set: set, When: when}}}
+	}
+
+	if err := ValidateRouting(route("secured", "input.a > 1"), sets); err != nil {
+		t.Errorf("valid routing rejected: %v", err)
+	}
+	if err := ValidateRouting(route("secured", ""), sets); err == nil {
+		t.Error("route without when accepted")
+	}
+	if err := ValidateRouting(route("secured", "input.a >"), sets); err == nil {
+		t.Error("route with an invalid expression accepted")
+	}
+	if err := ValidateRouting(route("secrued", "input.a > 1"), sets); !errors.Is(err, ErrUnknownMappingSet) {
+		t.Errorf("route to a misspelt set: err %v, want ErrUnknownMappingSet", err)
+	}
+	if err := ValidateRouting(models.Routing{Default: "unsecured"}, sets); !errors.Is(err, ErrUnknownMappingSet) {
+		t.Errorf("unknown default: err %v, want ErrUnknownMappingSet", err)
+	}
+}
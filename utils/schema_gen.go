@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// jsonSchemaDialect is the $schema URI of generated schemas.
+const jsonSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+// transformOutputTypes is the JSON type of the transform types whose result
+// type does not depend on the data. The others copy their source value
+// unchanged (ApplyTransform converts nothing) or, with transform logic, yield
+// whatever the expression returns, so their type is only known from
+// constraints.
+var transformOutputTypes = map[string]string{
+	"group":   "object",
+	"ungroup": "array",
+}
+
+// GenerateOutputSchema describes the document rules produce. Destination
+// paths become nested properties, numeric keys become array items, and
+// required rules make every key on their path required. Leaf types come from
+// the rule's constraints, else from its transform type. Defaults are typed as
+// the transform writes them.
+func GenerateOutputSchema(title string, rules []models.MappingRule) map[string]interface{} {
+	root := newRootSchema(title)
+	for _, rule := range rules {
+		leaf := schemaAtPath(root, rule.DestinationPath, rule.Required)
+		if leaf == nil {
+			continue
+		}
+		for key := range leaf {
+			delete(leaf, key)
+		}
+		leaf["description"] = fmt.Sprintf("From %s via %s", strings.Join(rule.SourcePath, "."), rule.TransformType)
+		if t, ok := transformOutputTypes[rule.TransformType]; ok {
+			leaf["type"] = t
+		}
+		if rule.Required && rule.DefaultValue != "" {
+			leaf["default"] = ParseDefaultValue(rule.DefaultValue)
+		}
+		if c := rule.Constraints; c != nil {
+			if c.Type != "" {
+				leaf["type"] = c.Type
+			}
+			if c.Pattern != "" {
+				leaf["pattern"] = c.Pattern
+			}
+			if len(c.Enum) > 0 {
+				leaf["enum"] = c.Enum
+			}
+			if c.Min != nil {
+				leaf["minimum"] = *c.Min
+			}
+			if c.Max != nil {
+				leaf["maximum"] = *c.Max
+			}
+			if c.MinLength != nil {
+				leaf[lengthKeyword(leaf, "minLength", "minItems")] = *c.MinLength
+			}
+			if c.MaxLength != nil {
+				leaf[lengthKeyword(leaf, "maxLength", "maxItems")] = *c.MaxLength
+			}
+		}
+	}
+	return root
+}
+
+// GenerateInputSchema describes the input paths rules read, through their
+// source paths or their expressions. A source path is required when its rule
+// is required and has no default value to fall back on.
+func GenerateInputSchema(title string, rules []models.MappingRule) map[string]interface{} {
+	root := newRootSchema(title)
+	for _, rule := range rules {
+		required := rule.Required && rule.DefaultValue == ""
+		for i, path := range ConsumedInputPaths(rule) {
+			if len(path) == 0 {
+				continue
+			}
+			schemaAtPath(root, path, required && i == 0)
+		}
+	}
+	return root
+}
+
+func newRootSchema(title string) map[string]interface{} {
+	return map[string]interface{}{
+		"$schema":    jsonSchemaDialect,
+		"title":      title,
+		"type":       "object",
+		"properties": map[string]interface{}{},
+	}
+}
+
+// schemaAtPath returns the schema for path below root, creating objects for
+// named keys and arrays for numeric ones. A node that was a leaf becomes a
+// container when a later path goes through it. When required is set every
+// key on the way is added to its object's required list and arrays get a
+// minItems covering the index.
+func schemaAtPath(root map[string]interface{}, path []string, required bool) map[string]interface{} {
+	if len(path) == 0 {
+		return nil
+	}
+	current := root
+	for _, key := range path {
+		if idx, err := strconv.Atoi(key); err == nil && idx >= 0 {
+			if current["type"] != "array" {
+				resetContainer(current, "array")
+			}
+			items, ok := current["items"].(map[string]interface{})
+			if !ok {
+				items = map[string]interface{}{}
+				current["items"] = items
+			}
+			if required {
+				if minItems, _ := current["minItems"].(int); minItems < idx+1 {
+					current["minItems"] = idx + 1
+				}
+			}
+			current = items
+			continue
+		}
+
+		if current["type"] != "object" {
+			resetContainer(current, "object")
+		}
+		properties, ok := current["properties"].(map[string]interface{})
+		if !ok {
+			properties = map[string]interface{}{}
+			current["properties"] = properties
+		}
+		child, ok := properties[key].(map[string]interface{})
+		if !ok {
+			child = map[string]interface{}{}
+			properties[key] = child
+		}
+		if required {
+			addRequired(current, key)
+		}
+		current = child
+	}
+	return current
+}
+
+// resetContainer turns a schema into an empty container of the given type,
+// dropping leaf keywords that no longer apply.
+func resetContainer(schema map[string]interface{}, typ string) {
+	for key := range schema {
+		switch key {
+		case "$schema", "title", "description", "required", "properties", "items", "minItems":
+		default:
+			delete(schema, key)
+		}
+	}
+	if typ == "array" {
+		delete(schema, "properties")
+		delete(schema, "required")
+	} else {
+		delete(schema, "items")
+		delete(schema, "minItems")
+	}
+	schema["type"] = typ
+}
+
+func addRequired(schema map[string]interface{}, key string) {
+	list, _ := schema["required"].([]string)
+	for _, existing := range list {
+		if existing == key {
+			return
+		}
+	}
+	schema["required"] = append(list, key)
+}
+
+func lengthKeyword(schema map[string]interface{}, stringKeyword, arrayKeyword string) string {
+	if schema["type"] == "array" {
+		return arrayKeyword
+	}
+	return stringKeyword
+}
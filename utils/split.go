@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ErrSplitInput is wrapped by the errors returned when the input has no array
+// at the split_on path.
+var ErrSplitInput = errors.New("cannot split input")
+
+// SplitInput returns one input per element of the array at path. Each input
+// is input with that array reduced to the one element, so rules written
+// against path.0 read the current element while every other path still
+// reads the shared data. Only the containers along path are copied.
+func SplitInput(input map[string]interface{}, path []string) ([]map[string]interface{}, error) {
+	value, ok := GetNestedValue(input, path)
+	if !ok {
+		return nil, fmt.Errorf("%w: split_on path %s not found", ErrSplitInput, strings.Join(path, "."))
+	}
+	elems, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: split_on path %s is not an array", ErrSplitInput, strings.Join(path, "."))
+	}
+
+	inputs := make([]map[string]interface{}, len(elems))
+	for i, elem := range elems {
+		inputs[i] = replaceAt(input, path, []interface{}{elem}).(map[string]interface{})
+	}
+	return inputs, nil
+}
+
+// replaceAt returns a copy of node with the value at path replaced. Path is
+// known to exist in node.
+func replaceAt(node interface{}, path []string, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	switch v := node.(type) {
+	case map[string]interface{}:
+		copied := make(map[string]interface{}, len(v))
+		for k, child := range v {
+			copied[k] = child
+		}
+		copied[path[0]] = replaceAt(v[path[0]], path[1:], value)
+		return copied
+	case []interface{}:
+		idx, _ := strconv.Atoi(path[0])
+		copied := append([]interface{}{}, v...)
+		copied[idx] = replaceAt(v[idx], path[1:], value)
+		return copied
+	}
+	return node
+}
+
+// TransformDocuments transforms input into one document, or into one
+// document per element of the opts.SplitOn array when that is set. With
+// opts.Explain set it also returns the pipeline trace of each document.
+func TransformDocuments(input map[string]interface{}, rules []models.MappingRule, opts TransformOptions) ([]map[string]interface{}, [][]StageTrace, error) {
+	inputs := []map[string]interface{}{input}
+	if len(opts.SplitOn) > 0 {
+		var err error
+		if inputs, err = SplitInput(input, opts.SplitOn); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	documents := make([]map[string]interface{}, 0, len(inputs))
+	var traces [][]StageTrace
+	for _, in := range inputs {
+		output, trace, err := TransformTraced(in, rules, opts)
+		if err != nil {
+			return nil, nil, err
+		}
+		documents = append(documents, output)
+		if opts.Explain {
+			traces = append(traces, trace)
+		}
+	}
+	return documents, traces, nil
+}
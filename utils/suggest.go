@@ -0,0 +1,258 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RuleSuggestion is a proposed mapping rule. Matches counts the pairs in which
+// the rule reproduces the expected value and Pairs the pairs that contain the
+// destination at all. Confidence weighs that support by how reliable the
+// kind of match is and by how many other sources fit equally well.
+type RuleSuggestion struct {
+	Rule         models.MappingRule `json:"rule"`
+	Confidence   float64            `json:"confidence"`
+	Matches      int                `json:"matches"`
+	Pairs        int                `json:"pairs"`
+	Alternatives []RuleSuggestion   `json:"alternatives,omitempty"`
+}
+
+// SuggestionReport holds the best suggestion per output path and the output
+// paths no input value could explain.
+type SuggestionReport struct {
+	Suggestions []RuleSuggestion `json:"suggestions"`
+	Unmatched   []string         `json:"unmatched"`
+}
+
+// maxAlternatives caps the runner-up suggestions kept per output path.
+const maxAlternatives = 3
+
+// blankValueWeight scales the confidence of paths whose expected value was
+// only ever null, false, zero or empty, since many inputs match those by
+// chance.
+const blankValueWeight = 0.5
+
+// dateOutputLayouts are the layouts tried when a date input was reformatted.
+var dateOutputLayouts = []string{
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+	"2006-01-02 15:04:05",
+	time.RFC3339,
+	"02-01-2006",
+	"02/01/2006",
+	"01/02/2006",
+	"02-Jan-2006",
+	"02-January-2006",
+	"20060102",
+}
+
+// matchKind is one way an output value can be derived from an input value.
+// Rules only carry a transform_logic when the transform type alone would not
+// produce the value.
+type matchKind struct {
+	transformType string
+	weight        float64
+	derive        func(in, out interface{}) (logic string, ok bool)
+}
+
+var matchKinds = []matchKind{
+	{"copy", 1.0, func(in, out interface{}) (string, bool) {
+		return "", reflect.DeepEqual(in, out)
+	}},
+	{"toUpperCase", 0.9, func(in, out interface{}) (string, bool) {
+		s, t, ok := stringPair(in, out)
+		return "toUpper(value)", ok && s != t && strings.ToUpper(s) == t
+	}},
+	{"toLowerCase", 0.9, func(in, out interface{}) (string, bool) {
+		s, t, ok := stringPair(in, out)
+		return "toLower(value)", ok && s != t && strings.ToLower(s) == t
+	}},
+	{"formatDate", 0.9, func(in, out interface{}) (string, bool) {
+		s, t, ok := stringPair(in, out)
+		if !ok {
+			return "", false
+		}
+		for _, inLayout := range dateInputLayouts {
+			parsed, err := time.Parse(inLayout, s)
+			if err != nil {
+				continue
+			}
+			for _, outLayout := range dateOutputLayouts {
+				if parsed.Format(outLayout) == t && s != t {
+					return fmt.Sprintf("formatDate(value, %q)", outLayout), true
+				}
+			}
+		}
+		return "", false
+	}},
+	{"expression", 0.85, func(in, out interface{}) (string, bool) {
+		s, ok := in.(string)
+		n, isNumber := out.(float64)
+		if !ok || !isNumber {
+			return "", false
+		}
+		if i, err := strconv.Atoi(strings.TrimSpace(s)); err == nil && float64(i) == n {
+			return "toInt(value)", true
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(s), 64); err == nil && f == n {
+			return "toFloat(value)", true
+		}
+		return "", false
+	}},
+}
+
+func isBlankValue(v interface{}) bool {
+	switch val := v.(type) {
+	case nil:
+		return true
+	case bool:
+		return !val
+	case float64:
+		return val == 0
+	case string:
+		return strings.TrimSpace(val) == ""
+	}
+	return false
+}
+
+func stringPair(in, out interface{}) (string, string, bool) {
+	s, ok1 := in.(string)
+	t, ok2 := out.(string)
+	return s, t, ok1 && ok2
+}
+
+// SuggestRules proposes one rule per output leaf from sample pairs. Each
+// output value is compared with every input value; candidates are keyed by
+// source, transform and logic and scored across all pairs.
+func SuggestRules(pairs []models.SamplePair) SuggestionReport {
+	type candidate struct {
+		source []string
+		kind   matchKind
+		logic  string
+		hits   int
+	}
+	type target struct {
+		path       []string
+		pairs      int
+		meaningful bool
+		candidates map[string]*candidate
+		order      []string
+	}
+	targets := make(map[string]*target)
+	var targetOrder []string
+
+	for _, pair := range pairs {
+		input, _ := normalizeJSON(pair.Input).(map[string]interface{})
+		output, _ := normalizeJSON(pair.Output).(map[string]interface{})
+		inputLeaves := scalarLeaves(input)
+
+		for _, outLeaf := range scalarLeaves(output) {
+			key := strings.Join(outLeaf.path, ".")
+			t, ok := targets[key]
+			if !ok {
+				t = &target{path: outLeaf.path, candidates: make(map[string]*candidate)}
+				targets[key] = t
+				targetOrder = append(targetOrder, key)
+			}
+			t.pairs++
+			if !isBlankValue(outLeaf.value) {
+				t.meaningful = true
+			}
+
+			// The first matching kind wins, so an equal value is a copy even
+			// when it is also, say, already upper case.
+			for _, inLeaf := range inputLeaves {
+				for _, kind := range matchKinds {
+					logic, ok := kind.derive(inLeaf.value, outLeaf.value)
+					if !ok {
+						continue
+					}
+					id := strings.Join(inLeaf.path, "\x00") + "\x01" + kind.transformType + "\x01" + logic
+					c, ok := t.candidates[id]
+					if !ok {
+						c = &candidate{source: inLeaf.path, kind: kind, logic: logic}
+						t.candidates[id] = c
+						t.order = append(t.order, id)
+					}
+					c.hits++
+					break
+				}
+			}
+		}
+	}
+
+	report := SuggestionReport{Suggestions: []RuleSuggestion{}, Unmatched: []string{}}
+	for _, key := range targetOrder {
+		t := targets[key]
+		if len(t.candidates) == 0 {
+			report.Unmatched = append(report.Unmatched, key)
+			continue
+		}
+
+		scored := make([]RuleSuggestion, 0, len(t.candidates))
+		for _, id := range t.order {
+			c := t.candidates[id]
+			rivals := 0
+			for _, other := range t.candidates {
+				if other.hits >= c.hits {
+					rivals++
+				}
+			}
+			support := float64(c.hits) / float64(t.pairs)
+			if !t.meaningful {
+				support *= blankValueWeight
+			}
+			scored = append(scored, RuleSuggestion{
+				Rule: models.MappingRule{
+					SourcePath:      c.source,
+					DestinationPath: t.path,
+					TransformType:   c.kind.transformType,
+					TransformLogic:  c.logic,
+				},
+				Confidence: math.Round(c.kind.weight*support/float64(rivals)*100) / 100,
+				Matches:    c.hits,
+				Pairs:      t.pairs,
+			})
+		}
+		sort.SliceStable(scored, func(i, j int) bool { return scored[i].Confidence > scored[j].Confidence })
+
+		best := scored[0]
+		if alternatives := scored[1:]; len(alternatives) > 0 {
+			if len(alternatives) > maxAlternatives {
+				alternatives = alternatives[:maxAlternatives]
+			}
+			best.Alternatives = alternatives
+		}
+		report.Suggestions = append(report.Suggestions, best)
+	}
+	return report
+}
+
+type scalarLeaf struct {
+	path  []string
+	value interface{}
+}
+
+// scalarLeaves lists the scalar values of a document with their paths, in a
+// stable order. Empty containers are skipped since there is no value to match.
+func scalarLeaves(data map[string]interface{}) []scalarLeaf {
+	var leaves []scalarLeaf
+	for _, path := range LeafPaths(data) {
+		value, _ := GetNestedValue(data, path)
+		switch value.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		leaves = append(leaves, scalarLeaf{path: path, value: value})
+	}
+	sort.SliceStable(leaves, func(i, j int) bool {
+		return strings.Join(leaves[i].path, ".") < strings.Join(leaves[j].path, ".")
+	})
+	return leaves
+}
@@ -68,10 +68,36 @@ func Transform(input map[string]interface{}, rules []models.MappingRule) (map[st
 	return ApplyRules(input, rules), nil
 }
 
+// TransformOptions holds the per-client settings applied around the rules.
+// SplitOn is only honoured by TransformDocuments.
+type TransformOptions struct {
+	PassThrough models.PassThrough
+	SplitOn     []string
+	Pipeline    models.Pipeline
+	Explain     bool
+	// Template, when set, is rendered by the map stage instead of applying
+	// the rules.
+	Template map[string]interface{}
+}
+
+// TransformWithOptions transforms input with rules through the client
+// pipeline, which applies the client options to the output.
+func TransformWithOptions(input map[string]interface{}, rules []models.MappingRule, opts TransformOptions) (map[string]interface{}, error) {
+	output, _, err := TransformTraced(input, rules, opts)
+	return output, err
+}
+
 func ApplyRules(input map[string]interface{}, rules []models.MappingRule) map[string]interface{} {
+	return applyRules(input, input, rules)
+}
+
+// applyRules applies rules to input. Root is the document the transform
+// started from, which differs from input inside sub-rules and sub-mappings
+// and is available to their expressions as root.
+func applyRules(input, root map[string]interface{}, rules []models.MappingRule) map[string]interface{} {
 	output := make(map[string]interface{})
 	for _, rule := range rules {
-		val, exists := GetNestedValue(input, rule.SourcePath)
+		val, exists := ResolveSourcePath(input, rule.SourcePath)
 		var transformedVal interface{}
 		var err error
 
@@ -80,22 +106,7 @@ func ApplyRules(input map[string]interface{}, rules []models.MappingRule) map[st
 			if rule.Required {
 				// For required fields, use default value if provided
 				if rule.DefaultValue != "" {
-					// Try to parse default value based on expected type
-					var defaultVal interface{}
-					defaultVal = rule.DefaultValue
-
-					// Check if it's a boolean
-					if rule.DefaultValue == "true" || rule.DefaultValue == "false" {
-						defaultVal = (rule.DefaultValue == "true")
-					} else if val, err := strconv.Atoi(rule.DefaultValue); err == nil {
-						// Check if it's an integer
-						defaultVal = val
-					} else if val, err := strconv.ParseFloat(rule.DefaultValue, 64); err == nil {
-						// Check if it's a float
-						defaultVal = val
-					}
-
-					SetNestedValue(output, rule.DestinationPath, defaultVal)
+					SetNestedValue(output, rule.DestinationPath, ParseDefaultValue(rule.DefaultValue))
 				} else {
 					// No default value provided, but field is required
 					// Set an empty value based on destination field name hints
@@ -118,11 +129,16 @@ func ApplyRules(input map[string]interface{}, rules []models.MappingRule) map[st
 		}
 
 		// Normal transformation for existing fields
-		if rule.TransformType == "expression" || rule.TransformLogic != "" {
+		if rule.TransformType == "group" || rule.TransformType == "ungroup" {
+			transformedVal, err = applyGrouping(rule, val, input, root)
+		} else if rule.TransformType == "subMapping" {
+			transformedVal, err = applySubMapping(rule, val, root)
+		} else if rule.TransformType == "expression" || rule.TransformLogic != "" {
 			// Create a rich context with various helper functions and input data
 			params := map[string]interface{}{
 				"value":      val,
 				"input":      input,
+				"root":       root,
 				"output":     output,
 				"sourcePath": rule.SourcePath,
 				"destPath":   rule.DestinationPath,
@@ -157,6 +173,23 @@ func ApplyRules(input map[string]interface{}, rules []models.MappingRule) map[st
 	}
 	return output
 }
+
+// ParseDefaultValue types a rule's default value as the transform writes it:
+// true and false become booleans, numbers become integers or floats, and
+// anything else stays a string.
+func ParseDefaultValue(s string) interface{} {
+	if s == "true" || s == "false" {
+		return s == "true"
+	}
+	if val, err := strconv.Atoi(s); err == nil {
+		return val
+	}
+	if val, err := strconv.ParseFloat(s, 64); err == nil {
+		return val
+	}
+	return s
+}
+
 func ApplyTransform(value interface{}, transformType string) (interface{}, error) {
 	return value, nil
 }
@@ -244,6 +277,16 @@ func StreamTransformJSONWithRules(r io.Reader, w io.Writer, rules []models.Mappi
 	return nil
 }
 
+// dateInputLayouts are the layouts formatDate accepts for its input.
+var dateInputLayouts = []string{
+	"02-January-2006",
+	"02-Jan-2006",
+	"02/January/2006",
+	"02-January-06",
+	"2006-01-02",
+	time.RFC3339,
+}
+
 // expressionFuncs contains reusable functions for expression evaluation
 
 // EvaluateExpression evaluates an expression with rich context and helper functions
@@ -257,15 +300,7 @@ func EvaluateExpression(expression string, context map[string]interface{}) (inte
 
 		// Date/time functions
 		"formatDate": func(dateStr string, format string) string {
-			formats := []string{
-				"02-January-2006",
-				"02-Jan-2006",
-				"02/January/2006",
-				"02-January-06",
-				"2006-01-02",
-				time.RFC3339,
-			}
-			for _, f := range formats {
+			for _, f := range dateInputLayouts {
 				if t, err := time.Parse(f, dateStr); err == nil {
 					return t.Format(format)
 				}
@@ -399,6 +434,9 @@ func EvaluateExpression(expression string, context map[string]interface{}) (inte
 		"today":   time.Now().Format("2006-01-02"),
 		"isoDate": time.Now().Format(time.RFC3339),
 	}
+	for name, fn := range collectionFuncs() {
+		env[name] = fn
+	}
 
 	// Add any other context variables
 	for k, v := range context {
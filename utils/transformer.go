@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"data_mapping/models"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/antonmedv/expr"
+)
+
+// ErrTemplateRender is wrapped by the errors returned when a template
+// placeholder cannot be evaluated against the input.
+var ErrTemplateRender = errors.New("cannot render template")
+
+// placeholderPattern matches a {{ expression }} placeholder in a template
+// string.
+var placeholderPattern = regexp.MustCompile(`\{\{(.*?)\}\}`)
+
+// Transformer builds an output document from an input document. It is the
+// map stage of a pipeline.
+type Transformer interface {
+	Transform(input map[string]interface{}) (map[string]interface{}, error)
+}
+
+// RuleTransformer maps input with a list of mapping rules.
+type RuleTransformer struct {
+	Rules []models.MappingRule
+}
+
+func (t RuleTransformer) Transform(input map[string]interface{}) (map[string]interface{}, error) {
+	return Transform(input, t.Rules)
+}
+
+// TemplateTransformer renders an output template. A string that is a single
+// {{ expression }} is replaced by the expression's value, keeping its type;
+// placeholders inside longer strings are replaced by their text. Everything
+// else is copied as-is. Expressions see input and root, both the whole input,
+// and the functions EvaluateExpression provides.
+type TemplateTransformer struct {
+	Template map[string]interface{}
+}
+
+func (t TemplateTransformer) Transform(input map[string]interface{}) (map[string]interface{}, error) {
+	env := map[string]interface{}{"input": input, "root": input}
+	output, err := renderTemplate(t.Template, "", env)
+	if err != nil {
+		return nil, err
+	}
+	return output.(map[string]interface{}), nil
+}
+
+// NewTransformer returns the transformer for opts: the template one when
+// opts carries a template, else the one applying rules.
+func NewTransformer(rules []models.MappingRule, opts TransformOptions) Transformer {
+	if opts.Template != nil {
+		return TemplateTransformer{Template: opts.Template}
+	}
+	return RuleTransformer{Rules: rules}
+}
+
+// ValidateTemplate checks that a template is an object and that every
+// placeholder in it compiles.
+func ValidateTemplate(template interface{}) error {
+	if _, ok := template.(map[string]interface{}); !ok {
+		return errors.New("template must be a JSON object")
+	}
+	return validateTemplateValue(template, "")
+}
+
+func validateTemplateValue(v interface{}, path string) error {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for _, k := range sortedKeys(val) {
+			if err := validateTemplateValue(val[k], joinDotted(path, k)); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for i, elem := range val {
+			if err := validateTemplateValue(elem, joinDotted(path, strconv.Itoa(i))); err != nil {
+				return err
+			}
+		}
+	case string:
+		for _, m := range placeholderPattern.FindAllStringSubmatch(val, -1) {
+			source := strings.TrimSpace(m[1])
+			if source == "" {
+				return fmt.Errorf("%s: empty placeholder", displayPath(path))
+			}
+			if _, err := expr.Compile(source); err != nil {
+				return fmt.Errorf("%s: invalid expression %q: %s", displayPath(path), source, err.Error())
+			}
+		}
+		if rest := placeholderPattern.ReplaceAllString(val, ""); strings.Contains(rest, "{{") {
+			return fmt.Errorf("%s: unterminated placeholder", displayPath(path))
+		}
+	}
+	return nil
+}
+
+// renderTemplate returns a rendered copy of a template value.
+func renderTemplate(v interface{}, path string, env map[string]interface{}) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, child := range val {
+			rendered, err := renderTemplate(child, joinDotted(path, k), env)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = rendered
+		}
+		return out, nil
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, elem := range val {
+			rendered, err := renderTemplate(elem, joinDotted(path, strconv.Itoa(i)), env)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = rendered
+		}
+		return out, nil
+	case string:
+		return renderString(val, path, env)
+	}
+	return v, nil
+}
+
+// renderString evaluates the placeholders of a template string.
+func renderString(s, path string, env map[string]interface{}) (interface{}, error) {
+	matches := placeholderPattern.FindAllStringSubmatchIndex(s, -1)
+	if len(matches) == 0 {
+		return s, nil
+	}
+	evaluate := func(m []int) (interface{}, error) {
+		source := strings.TrimSpace(s[m[2]:m[3]])
+		result, err := EvaluateExpression(source, env)
+		if err != nil {
+			return nil, fmt.Errorf("%w at %s: %s", ErrTemplateRender, displayPath(path), err.Error())
+		}
+		return result, nil
+	}
+	if len(matches) == 1 && matches[0][0] == 0 && matches[0][1] == len(s) {
+		return evaluate(matches[0])
+	}
+
+	var b strings.Builder
+	last := 0
+	for _, m := range matches {
+		result, err := evaluate(m)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(s[last:m[0]])
+		b.WriteString(FormatScalar(result))
+		last = m[1]
+	}
+	b.WriteString(s[last:])
+	return b.String(), nil
+}
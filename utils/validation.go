@@ -51,9 +51,44 @@ func ValidateMappingRule(rule interface{}) error {
 		if r.TransformType == "expression" && r.TransformLogic == "" {
 			return fmt.Errorf("validation failed: TransformLogic is required when TransformType is 'expression'")
 		}
+		if r.TransformType == "group" && r.TransformLogic == "" {
+			return fmt.Errorf("validation failed: TransformLogic must hold the key expression when TransformType is 'group'")
+		}
+		if r.TransformType == "subMapping" && r.TransformLogic == "" {
+			return fmt.Errorf("validation failed: TransformLogic must name the sub-mapping when TransformType is 'subMapping'")
+		}
+		if r.TransformType == "ungroup" && strings.ContainsAny(r.TransformLogic, ". ") {
+			return fmt.Errorf("validation failed: TransformLogic must be a single field name when TransformType is 'ungroup'")
+		}
+
+		// Sub-rules map the elements of grouped arrays and are checked like
+		// top-level rules.
+		if len(r.SubRules) > 0 && r.TransformType != "group" && r.TransformType != "ungroup" {
+			return fmt.Errorf("validation failed: sub_rules are only allowed on group and ungroup rules")
+		}
+		for i, sub := range r.SubRules {
+			if err := ValidateMappingRule(sub); err != nil {
+				return fmt.Errorf("validation failed: sub_rules[%d]: %s", i, strings.TrimPrefix(err.Error(), "validation failed: "))
+			}
+		}
+
+		if models.IsJSONPath(r.DestinationPath) {
+			return fmt.Errorf("validation failed: destination_path cannot be a JSONPath expression")
+		}
+		if models.IsJSONPath(r.SourcePath) {
+			if _, err := CompileJSONPath(r.SourcePath[0]); err != nil {
+				return fmt.Errorf("validation failed: %s", err.Error())
+			}
+		}
+
+		if r.Constraints != nil {
+			if err := ValidateConstraints(*r.Constraints); err != nil {
+				return fmt.Errorf("validation failed: %s", err.Error())
+			}
+		}
 
 		// If TransformLogic is provided, try to validate it's a valid expression
-		if r.TransformLogic != "" {
+		if r.TransformLogic != "" && r.TransformType != "ungroup" && r.TransformType != "subMapping" {
 			if _, err := expr.Compile(r.TransformLogic); err != nil {
 				return fmt.Errorf("validation failed: Invalid expression syntax in TransformLogic: %s", err.Error())
 			}